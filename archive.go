@@ -0,0 +1,421 @@
+package anvil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+)
+
+var (
+	ErrUnknownArchive = errors.New("anvil: unknown archive format, must be .zip, .tar, .tar.gz or .tgz")
+	ErrNotRegionFile  = errors.New("anvil: archive file is not a region file")
+	ErrFileNotFound   = errors.New("anvil: file not found in archive")
+
+	// ErrExternalChunkOrder is returned by Walk for tar archives with an
+	// external chunk file stored after its region file.
+	ErrExternalChunkOrder = errors.New("anvil: external chunk file stored after its region in archive")
+)
+
+// ArchiveFileKind is the kind of world file found in an archive.
+type ArchiveFileKind int
+
+const (
+	ArchiveRegion = ArchiveFileKind(iota + 1)
+	ArchivePlayerData
+)
+
+// Archive is a world backup stored in a tar or zip archive. Tar archives
+// (optionally gzipped) can only be read sequentially with Walk, zip archives
+// additionally support random access with File.
+type Archive struct {
+	filename string
+
+	zip   *zip.ReadCloser
+	files map[string]*zip.File
+	file  *os.File
+
+	// mcc are the external chunk files of the regions not yet walked in a
+	// tar archive, by their path.
+	mccMutex sync.Mutex
+	mcc      map[string][]byte
+}
+
+// ArchiveFile is a region or player data file in an archive.
+type ArchiveFile struct {
+	// Path is the slash separated path of the file in the archive.
+	Path string
+	Kind ArchiveFileKind
+	Size int64
+
+	// RegionKind is the kind of chunks in a region file, from the region,
+	// entities or poi folder it's in.
+	RegionKind RegionKind
+
+	archive *Archive
+	zip     *zip.File
+	tar     io.Reader
+}
+
+// OpenArchive opens a world backup archive. The format is determined from
+// the filename's extension.
+func OpenArchive(filename string) (*Archive, error) {
+	a := &Archive{
+		filename: filename,
+		mcc:      make(map[string][]byte),
+	}
+
+	lower := strings.ToLower(filename)
+
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		rd, err := zip.OpenReader(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := os.Open(filename)
+		if err != nil {
+			rd.Close()
+			return nil, err
+		}
+
+		a.zip = rd
+		a.file = f
+		a.files = make(map[string]*zip.File)
+		for _, file := range rd.File {
+			a.files[cleanArchivePath(file.Name)] = file
+		}
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"),
+		strings.HasSuffix(lower, ".tgz"):
+	default:
+		return nil, ErrUnknownArchive
+	}
+
+	return a, nil
+}
+
+// Close closes the archive.
+func (a *Archive) Close() error {
+	if a.zip == nil {
+		return nil
+	}
+
+	a.file.Close()
+	return a.zip.Close()
+}
+
+// Walk calls fn for every region and player data file in the archive, in the
+// order they're stored. Region files of entities and poi folders are included,
+// with their RegionKind set. For tar archives the file can only be read during the
+// call to fn, and external chunk files must be stored before their region file,
+// otherwise ErrExternalChunkOrder is returned. If fn returns an error the walk
+// stops and the error is returned.
+func (a *Archive) Walk(fn func(f *ArchiveFile) error) error {
+	if a.zip != nil {
+		for _, file := range a.zip.File {
+			name := cleanArchivePath(file.Name)
+			kind, regionKind := archiveFileKind(name)
+			if kind == 0 {
+				continue
+			}
+
+			err := fn(&ArchiveFile{
+				Path:       name,
+				Kind:       kind,
+				Size:       int64(file.UncompressedSize64),
+				RegionKind: regionKind,
+				archive:    a,
+				zip:        file,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	f, err := os.Open(a.filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rd io.Reader = f
+	lower := strings.ToLower(a.filename)
+	if strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		rd = gz
+	}
+
+	a.mccMutex.Lock()
+	a.mcc = make(map[string][]byte)
+	a.mccMutex.Unlock()
+
+	// walked are the regions already walked, by their directory and region
+	walked := make(map[string]bool)
+
+	tr := tar.NewReader(rd)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := cleanArchivePath(header.Name)
+
+		if path.Ext(name) == ".mcc" {
+			chunk, err := parseExternalChunkFilename(name)
+			if err != nil {
+				continue
+			}
+
+			if walked[regionKey(path.Dir(name), chunk.Region())] {
+				return fmt.Errorf("%w: %s", ErrExternalChunkOrder, name)
+			}
+
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+
+			a.mccMutex.Lock()
+			a.mcc[name] = data
+			a.mccMutex.Unlock()
+			continue
+		}
+
+		kind, regionKind := archiveFileKind(name)
+		if kind == 0 {
+			continue
+		}
+
+		err = fn(&ArchiveFile{
+			Path:       name,
+			Kind:       kind,
+			Size:       header.Size,
+			RegionKind: regionKind,
+			archive:    a,
+			tar:        tr,
+		})
+		if err != nil {
+			return err
+		}
+
+		if kind == ArchiveRegion {
+			if region, err := ParseRegionFilename(name); err == nil {
+				walked[regionKey(path.Dir(name), region)] = true
+				a.dropExternal(path.Dir(name), region)
+			}
+		}
+	}
+}
+
+// dropExternal removes the external chunks of the region in the given
+// directory once it has been walked.
+func (a *Archive) dropExternal(dir string, region Region) {
+	a.mccMutex.Lock()
+	defer a.mccMutex.Unlock()
+
+	for name := range a.mcc {
+		chunk, err := parseExternalChunkFilename(name)
+		if err == nil && path.Dir(name) == dir && chunk.Region() == region {
+			delete(a.mcc, name)
+		}
+	}
+}
+
+func regionKey(dir string, region Region) string {
+	return fmt.Sprintf("%s/%d.%d", dir, region.X, region.Z)
+}
+
+// parseExternalChunkFilename returns the chunk of an external chunk file
+// from its filename, see ExternalChunkFilename.
+func parseExternalChunkFilename(filename string) (chunk Chunk, err error) {
+	parts := strings.Split(path.Base(filename), ".")
+	if len(parts) != 4 || parts[0] != "c" || parts[3] != "mcc" {
+		err = errors.New("must be of the form c.<x>.<z>.mcc")
+		return
+	}
+
+	chunk.X, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return
+	}
+
+	chunk.Z, err = strconv.Atoi(parts[2])
+
+	return
+}
+
+// File returns the region or player data file at the given path in a zip
+// archive. ErrFileNotFound is returned for tar archives as they don't support
+// random access.
+func (a *Archive) File(name string) (*ArchiveFile, error) {
+	name = cleanArchivePath(name)
+
+	file, found := a.files[name]
+	kind, regionKind := archiveFileKind(name)
+	if !found || kind == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, name)
+	}
+
+	return &ArchiveFile{
+		Path:       name,
+		Kind:       kind,
+		Size:       int64(file.UncompressedSize64),
+		RegionKind: regionKind,
+		archive:    a,
+		zip:        file,
+	}, nil
+}
+
+func cleanArchivePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// archiveFileKind returns the kind of the file at the given path, or 0 if it
+// isn't a region or player data file, and the kind of chunks for region files.
+func archiveFileKind(name string) (ArchiveFileKind, RegionKind) {
+	dir := path.Base(path.Dir(name))
+	regionKind, isRegionDir := regionFolderKind(dir)
+
+	switch {
+	case isRegionDir && (path.Ext(name) == ".mca" || path.Ext(name) == ".mcr" ||
+		path.Ext(name) == ".linear"):
+		return ArchiveRegion, regionKind
+	case dir == "playerdata" && path.Ext(name) == ".dat":
+		return ArchivePlayerData, 0
+	default:
+		return 0, 0
+	}
+}
+
+// Open returns a reader over the contents of the file. Player data files can
+// be passed to nbt.NewGzipReader.
+func (f *ArchiveFile) Open() (io.ReadCloser, error) {
+	if f.zip != nil {
+		return f.zip.Open()
+	}
+
+	return ioutil.NopCloser(f.tar), nil
+}
+
+// Player returns the UUID of the player of a player data file.
+func (f *ArchiveFile) Player() string {
+	return strings.TrimSuffix(path.Base(f.Path), ".dat")
+}
+
+// Region opens the region file. Regions stored without compression in zip
+// archives are read directly from the archive, otherwise the region is
+// decompressed into memory. External chunks in tar archives are only
+// available during the call to Walk's fn.
+func (f *ArchiveFile) Region() (*RegionReader, error) {
+	if f.Kind != ArchiveRegion {
+		return nil, ErrNotRegionFile
+	}
+
+	region, err := ParseRegionFilename(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("anvil: not a valid region filename: %w", err)
+	}
+
+	var rd *RegionReader
+
+	if regionFileFormat(f.Path) == FormatLinear {
+		data, err := f.readAll()
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLinearRegionReader(data, region)
+	} else if f.zip != nil && f.zip.Method == zip.Store {
+		offset, err := f.zip.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+
+		rd, err = NewRegionReader(io.NewSectionReader(f.archive.file, offset, f.Size),
+			f.Size, region)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := f.readAll()
+		if err != nil {
+			return nil, err
+		}
+
+		rd, err = NewRegionReader(bytes.NewReader(data), int64(len(data)), region)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rd.Format = regionFileFormat(f.Path)
+
+	dir := path.Dir(f.Path)
+	rd.ReadExternal = func(chunk Chunk) ([]byte, error) {
+		return f.archive.readExternal(path.Join(dir, ExternalChunkFilename(chunk)))
+	}
+
+	return rd, nil
+}
+
+func (f *ArchiveFile) readAll() ([]byte, error) {
+	body, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+func (a *Archive) readExternal(name string) ([]byte, error) {
+	if a.zip != nil {
+		file, found := a.files[name]
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrFileNotFound, name)
+		}
+
+		rd, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rd.Close()
+
+		return ioutil.ReadAll(rd)
+	}
+
+	a.mccMutex.Lock()
+	data, found := a.mcc[name]
+	a.mccMutex.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, name)
+	}
+
+	return data, nil
+}
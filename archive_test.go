@@ -0,0 +1,221 @@
+package anvil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestArchives(t *testing.T, dir string, files map[string][]byte,
+	order []string) (string, string) {
+	tarName := filepath.Join(dir, "world.tar.gz")
+	f, err := os.Create(tarName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range order {
+		tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+			Typeflag: tar.TypeReg,
+		})
+		tw.Write(files[name])
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	zipName := filepath.Join(dir, "world.zip")
+	f, err = os.Create(zipName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+
+	for _, name := range order {
+		// store the overworld region uncompressed to test reading it from
+		// the archive directly
+		method := zip.Deflate
+		if cleanArchivePath(name) == "world/region/r.0.0.mca" {
+			method = zip.Store
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(files[name])
+	}
+	zw.Close()
+	f.Close()
+
+	return tarName, zipName
+}
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	regionName := filepath.Join(dir, "r.0.0.mca")
+	writeTestRegion(t, regionName, []testChunk{
+		{Chunk{X: 1, Z: 1}, CompressionUncompressed, []byte("terrain"), 0},
+		{Chunk{X: 2, Z: 1}, CompressionUncompressed | externalFlag, nil, 0},
+	})
+	region, err := ioutil.ReadFile(regionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"./world/region/c.2.1.mcc":      []byte("external"),
+		"./world/region/r.0.0.mca":      region,
+		"world/level.dat":               []byte("ignored"),
+		"world/DIM-1/region/r.0.0.mca":  region,
+		"world/entities/r.0.0.mca":      region,
+		"world/playerdata/abc-123.dat":  []byte("player"),
+		"world/playerdata/abc-123.json": []byte("ignored"),
+	}
+	order := []string{
+		"./world/region/c.2.1.mcc",
+		"./world/region/r.0.0.mca",
+		"world/level.dat",
+		"world/DIM-1/region/r.0.0.mca",
+		"world/entities/r.0.0.mca",
+		"world/playerdata/abc-123.dat",
+		"world/playerdata/abc-123.json",
+	}
+
+	tarName, zipName := writeTestArchives(t, dir, files, order)
+
+	for _, name := range []string{tarName, zipName} {
+		a, err := OpenArchive(name)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var paths []string
+		var regionKinds []RegionKind
+		err = a.Walk(func(f *ArchiveFile) error {
+			paths = append(paths, f.Path)
+
+			switch f.Kind {
+			case ArchiveRegion:
+				regionKinds = append(regionKinds, f.RegionKind)
+
+				rd, err := f.Region()
+				if !assert.NoError(t, err) {
+					return err
+				}
+
+				c, err := rd.ReadChunk(Chunk{X: 1, Z: 1})
+				assert.NoError(t, err)
+				assert.Equal(t, []byte("terrain"), c.Data)
+
+				if f.Path == "world/region/r.0.0.mca" {
+					c, err = rd.ReadChunk(Chunk{X: 2, Z: 1})
+					assert.NoError(t, err)
+					assert.Equal(t, []byte("external"), c.Data)
+				}
+			case ArchivePlayerData:
+				assert.Equal(t, "abc-123", f.Player())
+				rd, err := f.Open()
+				assert.NoError(t, err)
+				data, err := ioutil.ReadAll(rd)
+				assert.NoError(t, err)
+				assert.Equal(t, []byte("player"), data)
+			}
+
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, a.mcc)
+
+		assert.Equal(t, []string{
+			"world/region/r.0.0.mca",
+			"world/DIM-1/region/r.0.0.mca",
+			"world/entities/r.0.0.mca",
+			"world/playerdata/abc-123.dat",
+		}, paths)
+		assert.Equal(t, []RegionKind{RegionTerrain, RegionTerrain, RegionEntities},
+			regionKinds)
+
+		f, err := a.File("world/DIM-1/region/r.0.0.mca")
+		if name == zipName {
+			assert.NoError(t, err)
+			assert.Equal(t, ArchiveRegion, f.Kind)
+
+			f, err = a.File("world/entities/r.0.0.mca")
+			assert.NoError(t, err)
+			assert.Equal(t, RegionEntities, f.RegionKind)
+		} else {
+			assert.Error(t, err)
+		}
+
+		assert.NoError(t, a.Close())
+	}
+
+	_, err = OpenArchive(filepath.Join(dir, "world.rar"))
+	assert.Equal(t, ErrUnknownArchive, err)
+}
+
+func TestArchiveExternalChunkOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	regionName := filepath.Join(dir, "r.0.0.mca")
+	writeTestRegion(t, regionName, []testChunk{
+		{Chunk{X: 2, Z: 1}, CompressionUncompressed | externalFlag, nil, 0},
+	})
+	region, err := ioutil.ReadFile(regionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"world/region/r.0.0.mca":  region,
+		"world/region/c.2.1.mcc":  []byte("external"),
+		"world/region/c.40.1.mcc": []byte("other region"),
+	}
+	order := []string{
+		"world/region/r.0.0.mca",
+		"world/region/c.40.1.mcc",
+		"world/region/c.2.1.mcc",
+	}
+
+	tarName, zipName := writeTestArchives(t, dir, files, order)
+
+	for _, name := range []string{tarName, zipName} {
+		a, err := OpenArchive(name)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = a.Walk(func(f *ArchiveFile) error {
+			return nil
+		})
+		if name == tarName {
+			assert.True(t, errors.Is(err, ErrExternalChunkOrder))
+		} else {
+			assert.NoError(t, err)
+		}
+
+		assert.NoError(t, a.Close())
+	}
+}
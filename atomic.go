@@ -0,0 +1,345 @@
+package anvil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// SessionLockFilename is the name of the lock file held by a server while it
+// has a world open.
+const SessionLockFilename = "session.lock"
+
+var (
+	ErrWorldLocked  = errors.New("anvil: world is in use by a running server (session.lock is held)")
+	ErrNotAtomic    = errors.New("anvil: region writer was not opened with OpenRegionWriterAtomic")
+	ErrWriterClosed = errors.New("anvil: region writer has already been committed or closed")
+
+	// ErrLockUnsupported is returned on platforms where session.lock can't
+	// be checked, use AtomicOptions.Force to write anyway.
+	ErrLockUnsupported = errors.New("anvil: can't check session.lock on this platform")
+)
+
+// AtomicOptions are the options for OpenRegionWriterAtomic.
+type AtomicOptions struct {
+	// Backup keeps the previous version of the region file as
+	// r.<x>.<z>.mca.bak when the changes are committed.
+	Backup bool
+
+	// Force skips the session.lock check. Only use this if you're sure the
+	// server isn't running, the server will overwrite your changes anyway.
+	Force bool
+
+	// WorldDir is the world directory containing session.lock. If empty,
+	// the parent directories of the region file are searched for it.
+	WorldDir string
+}
+
+type atomicWrite struct {
+	target string
+	backup bool
+
+	// externals are the pending external chunk files, a nil value means
+	// the file should be deleted.
+	externals map[string][]byte
+	done      bool
+}
+
+// WorldLocked returns whether the world's session.lock is held by a running
+// server. A world without a session.lock is not locked. ErrLockUnsupported is
+// returned on platforms where the lock can't be checked.
+func WorldLocked(worldDir string) (bool, error) {
+	f, err := os.Open(filepath.Join(worldDir, SessionLockFilename))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return sessionLockHeld(f)
+}
+
+// findWorldDir searches the parents of the region directory for the world
+// directory containing session.lock, such as world/ for world/DIM-1/region.
+func findWorldDir(regionDir string) string {
+	dir, err := filepath.Abs(regionDir)
+	if err != nil {
+		return ""
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := os.Stat(filepath.Join(dir, SessionLockFilename)); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return ""
+}
+
+// checkWorldLock returns ErrWorldLocked if the world containing the file is in
+// use by a running server, unless opts.Force is set.
+func checkWorldLock(filename string, opts AtomicOptions) error {
+	if opts.Force {
+		return nil
+	}
+
+	worldDir := opts.WorldDir
+	if worldDir == "" {
+		worldDir = findWorldDir(filepath.Dir(filename))
+	}
+
+	if worldDir == "" {
+		return nil
+	}
+
+	locked, err := WorldLocked(worldDir)
+	if err != nil {
+		return fmt.Errorf("anvil: failed to check session.lock: %w", err)
+	}
+
+	if locked {
+		return ErrWorldLocked
+	}
+
+	return nil
+}
+
+// OpenRegionWriterAtomic opens a region writer whose changes are made to a
+// temporary copy of the region file. The changes are only applied when Commit
+// is called, by atomically renaming the copy over the original. Closing the
+// writer without committing discards the changes.
+//
+// ErrWorldLocked is returned if the world is in use by a running server,
+// unless opts.Force is set.
+func OpenRegionWriterAtomic(filename string, opts AtomicOptions) (*RegionWriter, error) {
+	return openRegionWriterAtomic(filename, opts, true)
+}
+
+// openRegionWriterAtomic opens an atomic region writer, which starts off as a
+// copy of the existing region if copyExisting is set, or empty otherwise.
+func openRegionWriterAtomic(filename string, opts AtomicOptions,
+	copyExisting bool) (*RegionWriter, error) {
+	region, err := ParseRegionFilename(filename)
+	if err != nil {
+		return nil, fmt.Errorf("anvil: not a valid region filename: %w", err)
+	} else if regionFileFormat(filename) == FormatLinear {
+		return nil, ErrLinearRegion
+	}
+
+	if err := checkWorldLock(filename, opts); err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := func() (*RegionWriter, error) {
+		// TempFile creates the file as 0600, which would be kept by the
+		// rename and could make the region unreadable by the server
+		if err := tmp.Chmod(targetFileMode(filename)); err != nil {
+			return nil, err
+		}
+
+		if !copyExisting {
+			return newRegionWriter(tmp, region, filename)
+		}
+
+		if err := copyFileTo(tmp, filename); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		return newRegionWriter(tmp, region, filename)
+	}()
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	w.atomic = &atomicWrite{
+		target:    filename,
+		backup:    opts.Backup,
+		externals: make(map[string][]byte),
+	}
+
+	return w, nil
+}
+
+func copyFileTo(dst *os.File, filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// Commit applies the changes made by an atomic region writer and closes it.
+func (w *RegionWriter) Commit() error {
+	a := w.atomic
+	if a == nil {
+		return ErrNotAtomic
+	} else if a.done {
+		return ErrWriterClosed
+	}
+	a.done = true
+
+	tmpName := w.file.Name()
+	defer os.Remove(tmpName)
+
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	for path, data := range a.externals {
+		if data == nil {
+			continue
+		}
+
+		if err := writeFileAtomic(path, data); err != nil {
+			return err
+		}
+	}
+
+	if a.backup {
+		if err := backupFile(a.target); err != nil {
+			return fmt.Errorf("anvil: failed to back up region: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmpName, a.target); err != nil {
+		return err
+	}
+
+	for path, data := range a.externals {
+		if data != nil {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the region writer. For atomic writers that haven't been
+// committed, the changes are discarded.
+func (w *RegionWriter) Close() error {
+	a := w.atomic
+	if a == nil {
+		return w.RegionReader.Close()
+	} else if a.done {
+		return nil
+	}
+	a.done = true
+
+	err := w.file.Close()
+	os.Remove(w.file.Name())
+	return err
+}
+
+// writeExternal writes an external chunk file, or deletes it if data is nil.
+// For atomic writers this is deferred until the changes are committed.
+func (w *RegionWriter) writeExternal(path string, data []byte) error {
+	if w.atomic != nil {
+		w.atomic.externals[path] = data
+		return nil
+	}
+
+	if data == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	return writeFileSync(path, data)
+}
+
+// readExternal reads an external chunk file, taking into account the pending
+// changes of atomic writers.
+func (w *RegionWriter) readExternal(chunk Chunk) ([]byte, error) {
+	path := filepath.Join(w.dir, ExternalChunkFilename(chunk))
+	if w.atomic != nil {
+		if data, found := w.atomic.externals[path]; found {
+			if data == nil {
+				return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+			}
+			return data, nil
+		}
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+// targetFileMode returns the permissions of the file, or 0644 if it doesn't
+// exist yet, for files that replace it.
+func targetFileMode(filename string) os.FileMode {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0644
+	}
+
+	return info.Mode().Perm()
+}
+
+func writeFileAtomic(filename string, data []byte) error {
+	tmpName := filename + ".tmp"
+	if err := writeFileSync(tmpName, data); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Chmod(tmpName, targetFileMode(filename)); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, filename)
+}
+
+// backupFile keeps a copy of the file as <filename>.bak, using a hard link
+// where possible.
+func backupFile(filename string) error {
+	backup := filename + ".bak"
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Link(filename, backup); err == nil || os.IsNotExist(err) {
+		return nil
+	}
+
+	f, err := os.Create(backup)
+	if err != nil {
+		return err
+	}
+
+	if err := copyFileTo(f, filename); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,107 @@
+package anvil
+
+// Box is an axis aligned box of blocks between Min and Max inclusive. Use
+// NewBox to construct one from any two opposite corners.
+//
+// Box also implements Selector, selecting the chunks it overlaps horizontally
+// like BoxSelector.
+type Box struct {
+	Min Coord
+	Max Coord
+}
+
+// NewBox returns the box with the given opposite corners, which may be in any
+// order.
+func NewBox(a, b Coord) Box {
+	return Box{
+		Min: Coord{X: minInt(a.X, b.X), Y: minInt(a.Y, b.Y), Z: minInt(a.Z, b.Z)},
+		Max: Coord{X: maxInt(a.X, b.X), Y: maxInt(a.Y, b.Y), Z: maxInt(a.Z, b.Z)},
+	}
+}
+
+// Contains returns whether the coordinate is inside the box.
+func (b Box) Contains(c Coord) bool {
+	return c.X >= b.Min.X && c.X <= b.Max.X &&
+		c.Y >= b.Min.Y && c.Y <= b.Max.Y &&
+		c.Z >= b.Min.Z && c.Z <= b.Max.Z
+}
+
+// Intersects returns whether the boxes have any block in common.
+func (b Box) Intersects(o Box) bool {
+	return b.Min.X <= o.Max.X && b.Max.X >= o.Min.X &&
+		b.Min.Y <= o.Max.Y && b.Max.Y >= o.Min.Y &&
+		b.Min.Z <= o.Max.Z && b.Max.Z >= o.Min.Z
+}
+
+// Chunks returns every chunk the box overlaps horizontally, ordered by Z then
+// X. The chunks' Y is 0.
+func (b Box) Chunks() []Chunk {
+	min, max := BoxSelector(b).chunkBounds()
+
+	chunks := make([]Chunk, 0, (max.X-min.X+1)*(max.Z-min.Z+1))
+	for z := min.Z; z <= max.Z; z++ {
+		for x := min.X; x <= max.X; x++ {
+			chunks = append(chunks, Chunk{X: x, Z: z})
+		}
+	}
+
+	return chunks
+}
+
+// Sections returns every section the box overlaps, ordered by Y, then Z, then
+// X.
+func (b Box) Sections() []SectionPos {
+	min, max := b.Min.Section(), b.Max.Section()
+
+	sections := make([]SectionPos, 0,
+		(max.X-min.X+1)*(max.Y-min.Y+1)*(max.Z-min.Z+1))
+	for y := min.Y; y <= max.Y; y++ {
+		for z := min.Z; z <= max.Z; z++ {
+			for x := min.X; x <= max.X; x++ {
+				sections = append(sections, SectionPos{X: x, Y: y, Z: z})
+			}
+		}
+	}
+
+	return sections
+}
+
+// Regions returns every region the box overlaps, ordered by Z then X.
+func (b Box) Regions() []Region {
+	min, max := b.Min.Region(), b.Max.Region()
+
+	regions := make([]Region, 0, (max.X-min.X+1)*(max.Z-min.Z+1))
+	for z := min.Z; z <= max.Z; z++ {
+		for x := min.X; x <= max.X; x++ {
+			regions = append(regions, Region{X: x, Z: z})
+		}
+	}
+
+	return regions
+}
+
+// ToNether returns the box covering the nether blocks corresponding to the
+// overworld blocks in the box.
+func (b Box) ToNether() Box {
+	return Box{Min: b.Min.ToNether(), Max: b.Max.ToNether()}
+}
+
+// ToOverworld returns the box covering the overworld blocks corresponding to
+// the nether blocks in the box.
+func (b Box) ToOverworld() Box {
+	max := b.Max.ToOverworld()
+	max.X += netherScale - 1
+	max.Z += netherScale - 1
+	return Box{Min: b.Min.ToOverworld(), Max: max}
+}
+
+// ContainsRegion returns whether the box overlaps the region horizontally.
+func (b Box) ContainsRegion(region Region) bool {
+	return BoxSelector(b).ContainsRegion(region)
+}
+
+// ContainsChunk returns whether the box overlaps the chunk horizontally. The
+// chunk's Y is ignored.
+func (b Box) ContainsChunk(chunk Chunk) bool {
+	return BoxSelector(b).ContainsChunk(chunk)
+}
@@ -118,7 +118,7 @@ func main() {
 					continue
 				}
 
-				fmt.Println(string(nrd.StructureToJSON(nrd.Index[0])))
+				fmt.Println(string(nrd.StructureToJSON(0)))
 
 				// fmt.Println("got match!")
 				results, err := nrd.MatchTags([][]byte{
@@ -135,7 +135,7 @@ func main() {
 
 				for _, result := range results {
 					var computerID int
-					nrd.SeekTo(result.Pos)
+					nrd.SeekTo(nrd.Index[result].Pos())
 					nrd.ReadImmediate(nbt.TagInt, &computerID)
 
 					computerResults <- PlayerComputer{
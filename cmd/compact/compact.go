@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/tmpim/anvil"
+)
+
+func main() {
+	workers := flag.Int("workers", runtime.NumCPU(), "number of regions to compact at once")
+	recompress := flag.String("recompress", "", "recompress chunks with gzip, zlib or uncompressed")
+	backup := flag.Bool("backup", false, "keep a .bak of compacted regions")
+	force := flag.Bool("force", false, "compact even if the world's session.lock is held")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: compact [flags] <region folder>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := anvil.CompactOptions{
+		Atomic: anvil.AtomicOptions{
+			Backup: *backup,
+			Force:  *force,
+		},
+	}
+
+	switch *recompress {
+	case "":
+	case "gzip":
+		opts.Recompress = anvil.CompressionGzip
+	case "zlib":
+		opts.Recompress = anvil.CompressionZlib
+	case "uncompressed":
+		opts.Recompress = anvil.CompressionUncompressed
+	default:
+		log.Fatalf("unsupported compression type %q", *recompress)
+	}
+
+	start := time.Now()
+
+	results, err := anvil.CompactRegionDir(flag.Arg(0), *workers, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	var reclaimed int64
+	failed := 0
+
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+
+		reclaimed += result.Reclaimed()
+
+		if err := enc.Encode(result); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Println("took:", time.Since(start))
+	log.Printf("compacted %d regions, %d failed, reclaimed %d bytes\n",
+		len(results)-failed, failed, reclaimed)
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
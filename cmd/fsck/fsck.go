@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/tmpim/anvil"
+	"github.com/tmpim/anvil/nbt"
+)
+
+func main() {
+	repair := flag.String("repair", "none", "repair mode: none, drop or relocate")
+	backup := flag.Bool("backup", true, "keep a .bak of repaired regions")
+	force := flag.Bool("force", false, "repair even if the world's session.lock is held")
+	headerOnly := flag.Bool("header-only", false, "only check the location tables")
+	all := flag.Bool("all", false, "also output reports for regions without problems")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: fsck [flags] <region folder or region files...>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := anvil.CheckOptions{
+		HeaderOnly: *headerOnly,
+		Validate:   nbt.Validate,
+		Atomic: anvil.AtomicOptions{
+			Backup: *backup,
+			Force:  *force,
+		},
+	}
+
+	switch *repair {
+	case "none":
+		opts.Repair = anvil.RepairNone
+	case "drop":
+		opts.Repair = anvil.RepairDrop
+	case "relocate":
+		opts.Repair = anvil.RepairRelocate
+	default:
+		log.Fatalf("unknown repair mode %q", *repair)
+	}
+
+	var regionFiles []string
+
+	for _, arg := range flag.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if !info.IsDir() {
+			regionFiles = append(regionFiles, arg)
+			continue
+		}
+
+		files, err := ioutil.ReadDir(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, file := range files {
+			if _, err := anvil.ParseRegionFilename(file.Name()); err == nil {
+				regionFiles = append(regionFiles, filepath.Join(arg, file.Name()))
+			}
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	bad := 0
+
+	for _, file := range regionFiles {
+		report, err := anvil.CheckRegion(file, opts)
+		if report == nil {
+			log.Printf("failed to check %q: %v\n", file, err)
+			bad++
+			continue
+		} else if err != nil {
+			log.Printf("failed to repair %q: %v\n", file, err)
+		}
+
+		if !report.OK() {
+			bad++
+		} else if !*all {
+			continue
+		}
+
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Printf("checked %d regions, %d with problems\n", len(regionFiles), bad)
+
+	if bad > 0 {
+		os.Exit(1)
+	}
+}
@@ -133,7 +133,7 @@ func main() {
 
 				for _, result := range results {
 					var computerID int
-					nrd.SeekTo(result.Pos)
+					nrd.SeekTo(nrd.Index[result].Pos())
 					nrd.ReadImmediate(nbt.TagInt, &computerID)
 
 					computerResults <- PlayerComputer{
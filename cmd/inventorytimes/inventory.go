@@ -102,9 +102,9 @@ func main() {
 				for _, res := range results {
 					nrd.SeekTo(res)
 					idx := nrd.AlignToIndex()
-					nrd.SeekTo(idx.Pos)
+					nrd.SeekTo(idx.Pos())
 
-					if idx.Header.TagID == nbt.TagString {
+					if idx.TagID() == nbt.TagString {
 						var result string
 						nrd.ReadImmediate(nbt.TagString, &result)
 						fmt.Println(result)
@@ -114,7 +114,7 @@ func main() {
 						statsMutex.Unlock()
 					}
 
-					// if idx.Header.TagID == nbt.TagString {
+					// if idx.TagID() == nbt.TagString {
 					// 	var title string
 					// 	nrd.ReadImmediate(nbt.TagString, &title)
 					// 	fmt.Println(title, ":", playerfile.Player)
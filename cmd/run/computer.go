@@ -14,20 +14,20 @@ type FoundComputer struct {
 	Approximate bool
 }
 
-func resolveComputer(nrd *nbt.Reader, ent *nbt.IndexEntry) []FoundComputer {
-	if !bytes.Equal(ent.Header.Name, []byte("computerID")) {
+func resolveComputer(nrd *nbt.Reader, i int) []FoundComputer {
+	if !bytes.Equal(nrd.EntryName(&nrd.Index[i]), []byte("computerID")) {
 		panic("breadcrumb must be to a computerID")
 	}
 
 	var computerID int
 
-	nrd.SeekTo(ent.Pos)
+	nrd.SeekTo(nrd.Index[i].Pos())
 	_, err := nrd.ReadImmediate(nbt.TagInt, &computerID)
 	if err != nil {
 		panic(err)
 	}
 
-	details := nrd.GetTileEntityDetails(ent)
+	details := nrd.GetTileEntityDetails(&nrd.Index[i])
 	if details == nil {
 		return nil
 	}
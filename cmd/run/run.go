@@ -1,14 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
 	"strconv"
-	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,21 +17,11 @@ import (
 // minX, maxX, minZ, maxZ: [-7268, 7732, -7496, 7504]
 
 func main() {
-	minCoord := anvil.Coord{
-		X: -7270,
-		Z: -7460,
+	sel := anvil.BoxSelector{
+		Min: anvil.Coord{X: -7270, Z: -7460},
+		Max: anvil.Coord{X: 7740, Z: 7550},
 	}
 
-	maxCoord := anvil.Coord{
-		X: 7740,
-		Z: 7550,
-	}
-
-	minRegion := minCoord.Region()
-	maxRegion := maxCoord.Region()
-	minChunk := minCoord.Chunk()
-	maxChunk := maxCoord.Chunk()
-
 	if len(os.Args) < 2 {
 		fmt.Println("specify the region folder pls")
 		return
@@ -58,19 +46,11 @@ func main() {
 
 	start := time.Now()
 
-	files, err := ioutil.ReadDir(os.Args[1])
+	regionFiles, err := anvil.RegionFiles(os.Args[1])
 	if err != nil {
 		panic(err)
 	}
 
-	var regionFiles []string
-
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".mca" {
-			regionFiles = append(regionFiles, filepath.Join(os.Args[1], file.Name()))
-		}
-	}
-
 	if len(regionFiles) == 0 {
 		fmt.Println("no regions found??? did you specify the right dir?")
 		os.Exit(1)
@@ -79,98 +59,32 @@ func main() {
 	var totalBytes int64
 	var totalComp int32
 
-	out := make(chan anvil.ChunkData, 1000)
-
 	computerResults := make(chan FoundComputer, 100)
 
-	go func() {
-		defer close(out)
-
-		wg := new(sync.WaitGroup)
-
-		for _, file := range regionFiles {
-			rd, err := anvil.OpenRegionFile(file)
-			if err != nil {
-				log.Printf("failed to open %q: %v\n", file, err)
-				continue
-			}
-
-			if rd.Region.X > maxRegion.X || rd.Region.Z > maxRegion.Z ||
-				rd.Region.X < minRegion.X || rd.Region.Z < minRegion.Z {
-				continue
-			}
-
-			if err := rd.ReadAllChunks(out); err != nil {
-				log.Printf("failed to read %q: %v\n", file, err)
-			}
-
-			count := atomic.LoadInt64(&totalBytes)
-			log.Println("processed:", file, "bytes:", count)
-		}
-
-		_ = wg
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			for _, file := range regionFiles[len(regionFiles)/2:] {
-				rd, err := anvil.OpenRegionFile(file)
-				if err != nil {
-					log.Printf("failed to open %q: %v\n", file, err)
-					continue
-				}
-
-				if rd.Region.X > maxRegion.X || rd.Region.Z > maxRegion.Z ||
-					rd.Region.X < minRegion.X || rd.Region.Z < minRegion.Z {
-					continue
-				}
-
-				if err := rd.ReadAllChunks(out); err != nil {
-					log.Printf("failed to read %q: %v\n", file, err)
-				}
-
-				count := atomic.LoadInt64(&totalBytes)
-				log.Println("processed:", file, "bytes:", count)
-			}
-		}()
-
-		wg.Wait()
-	}()
-
-	wg := new(sync.WaitGroup)
-
-	for i := 0; i < 12; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	scanner := &anvil.Scanner{
+		Workers:  12,
+		Selector: sel,
+		Progress: func(p anvil.ScanProgress) {
+			log.Printf("processed: %d/%d regions, bytes: %d, eta: %v\n",
+				p.RegionsDone, p.Regions, atomic.LoadInt64(&totalBytes),
+				p.ETA.Round(time.Second))
+		},
+	}
 
-			for chunk := range out {
-				if chunk.Chunk.X > maxChunk.X || chunk.Chunk.Z > maxChunk.Z ||
-					chunk.Chunk.X < minChunk.X || chunk.Chunk.Z < minChunk.Z {
-					continue
-				}
+	scanDone := make(chan struct{})
 
-				// s2 := time.Now()
+	go func() {
+		defer close(scanDone)
 
+		scanErrors, err := scanner.Scan(context.Background(), regionFiles,
+			func(chunk anvil.ChunkData) error {
 				nrd, err := nbt.NewTileEntitiesReader(&chunk)
 				if err != nil {
-					continue
-					// panic(err)
+					return nil
 				}
 
 				atomic.AddInt64(&totalBytes, int64(nrd.Len()))
 
-				// ok, err := nrd.PossibleTagMatch([][][]byte{
-				// 	{
-				// 		targetComputer,
-				// 	},
-				// })
-
-				// if !ok {
-				// 	continue
-				// }
-
 				nrd.ReadTagHeader()
 				start := nrd.Cursor()
 				nrd.SkipTag(nbt.TagList)
@@ -179,21 +93,16 @@ func main() {
 
 				atomic.AddInt32(&totalComp, int32(length))
 
-				// s, _, _ := nrd.ReadTagHeader()
-				// log.Println(s.TagID, string(s.Name))
-
-				// err = nrd.FastPrepareIndex()
-				// if err != nil {
-				// 	log.Println("fast prepare index:", err)
-				// 	continue
-				// }
+				return nil
+			})
+		if err != nil {
+			panic(err)
+		}
 
-				// ll := len(nrd.EncodeIndex())
-				// // log.Println("index size:", ll)
-				// atomic.AddInt32(&totalComp, int32(ll))
-			}
-		}()
-	}
+		for _, err := range scanErrors {
+			log.Println(err)
+		}
+	}()
 
 	compChan := make(chan struct{})
 
@@ -210,7 +119,7 @@ func main() {
 		}
 	}()
 
-	wg.Wait()
+	<-scanDone
 	close(computerResults)
 	<-compChan
 
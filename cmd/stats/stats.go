@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tmpim/anvil"
+)
+
+func main() {
+	format := flag.String("format", "table", "output format: table or json")
+	kind := flag.String("kind", "region", "region folders to read: region, entities or poi")
+	largest := flag.Int("largest", 5, "number of largest chunks to report")
+	regions := flag.Bool("regions", false, "also output the statistics of each region")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: stats [flags] <world folder>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	world, err := anvil.OpenWorld(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var results []*anvil.DimensionStats
+
+	for _, dim := range world.Dimensions {
+		switch *kind {
+		case "region":
+		case "entities":
+			dim = dim.Entities()
+		case "poi":
+			dim = dim.POI()
+		default:
+			log.Fatalf("unknown region folder kind %q", *kind)
+		}
+
+		if dim == nil {
+			continue
+		}
+
+		stats, err := dim.Stats(*largest)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, failed := range stats.Failed {
+			log.Printf("failed to read %s: %s", failed.Filename, failed.Error)
+		}
+
+		if !*regions {
+			stats.Regions = nil
+		}
+
+		results = append(results, stats)
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		for _, stats := range results {
+			if err := enc.Encode(stats); err != nil {
+				log.Fatal(err)
+			}
+		}
+	case "table":
+		printTable(results)
+	default:
+		log.Fatalf("unknown output format %q", *format)
+	}
+}
+
+func printTable(results []*anvil.DimensionStats) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\tchunks\tsize\tcompressed\twaste\tfree sectors\terrors\toldest\tnewest\t")
+
+	for _, dim := range results {
+		for _, region := range dim.Regions {
+			printRow(tw, fmt.Sprintf("  r.%d.%d", region.Region.X, region.Region.Z),
+				&region.StatTotals)
+		}
+
+		printRow(tw, dim.Name, &dim.StatTotals)
+	}
+
+	tw.Flush()
+
+	for _, dim := range results {
+		if len(dim.Largest) == 0 {
+			continue
+		}
+
+		fmt.Printf("\nlargest chunks in %s:\n", dim.Name)
+		for _, c := range dim.Largest {
+			external := ""
+			if c.External {
+				external = " (external)"
+			}
+
+			fmt.Printf("  %d, %d: %d bytes in %d sectors%s\n", c.Chunk.X, c.Chunk.Z,
+				c.Length, c.Sectors, external)
+		}
+	}
+}
+
+func printRow(tw *tabwriter.Writer, name string, t *anvil.StatTotals) {
+	fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t\n", name, t.Chunks, t.Size,
+		t.CompressedBytes, t.Waste, t.FreeSectors, t.Errors, formatTime(t.Oldest),
+		formatTime(t.Newest))
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -1,11 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
@@ -16,21 +15,11 @@ import (
 // minX, maxX, minZ, maxZ: [-7268, 7732, -7496, 7504]
 
 func main() {
-	minCoord := anvil.Coord{
-		X: -7270,
-		Z: -7460,
+	sel := anvil.BoxSelector{
+		Min: anvil.Coord{X: -7270, Z: -7460},
+		Max: anvil.Coord{X: 7740, Z: 7550},
 	}
 
-	maxCoord := anvil.Coord{
-		X: 7740,
-		Z: 7550,
-	}
-
-	minRegion := minCoord.Region()
-	maxRegion := maxCoord.Region()
-	minChunk := minCoord.Chunk()
-	maxChunk := maxCoord.Chunk()
-
 	if len(os.Args) < 2 {
 		fmt.Println("specify the region folder pls")
 		return
@@ -38,123 +27,90 @@ func main() {
 
 	start := time.Now()
 
-	files, err := ioutil.ReadDir(os.Args[1])
+	regionFiles, err := anvil.RegionFiles(os.Args[1])
 	if err != nil {
 		panic(err)
 	}
 
-	var regionFiles []string
-
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".mca" {
-			regionFiles = append(regionFiles, filepath.Join(os.Args[1], file.Name()))
-		}
-	}
-
 	if len(regionFiles) == 0 {
 		fmt.Println("no regions found??? did you specify the right dir?")
 		os.Exit(1)
 	}
 
-	out := make(chan anvil.ChunkData, 10)
+	statsMutex := new(sync.Mutex)
+	stats := make(map[string]int)
+
+	scanner := &anvil.Scanner{
+		Workers:  12,
+		Selector: sel,
+	}
 
-	go func() {
-		defer close(out)
+	scanErrors, err := scanner.Scan(context.Background(), regionFiles,
+		func(chunk anvil.ChunkData) error {
+			// s2 := time.Now()
 
-		for _, file := range regionFiles {
-			rd, err := anvil.OpenRegionFile(file)
+			nrd, err := nbt.NewRegionChunkReader(&chunk)
 			if err != nil {
-				log.Printf("failed to open %q: %v\n", file, err)
-				continue
+				return nil
+				// panic(err)
 			}
 
-			if rd.Region.X > maxRegion.X || rd.Region.Z > maxRegion.Z ||
-				rd.Region.X < minRegion.X || rd.Region.Z < minRegion.Z {
-				continue
+			results := nrd.SimpleMatch([]byte("The Transreich Trade Agreement"), -1)
+			if len(results) == 0 {
+				return nil
 			}
 
-			if err := rd.ReadAllChunks(out); err != nil {
-				log.Printf("failed to read %q: %v\n", file, err)
+			err = nrd.PrepareIndex(nbt.SelectiveIndex{
+				nbt.TagHeader{
+					TagID: nbt.TagList,
+					Name:  []byte("TileEntities"),
+				},
+			})
+			if err != nil {
+				return err
 			}
 
-			// log.Println("processed:", file)
-		}
-	}()
-
-	wg := new(sync.WaitGroup)
-	statsMutex := new(sync.Mutex)
-	stats := make(map[string]int)
-
-	for i := 0; i < 12; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			for chunk := range out {
-				if chunk.Chunk.X > maxChunk.X || chunk.Chunk.Z > maxChunk.Z ||
-					chunk.Chunk.X < minChunk.X || chunk.Chunk.Z < minChunk.Z {
+			for _, res := range results {
+				nrd.SeekTo(res)
+				idx := nrd.AlignToIndex()
+				if idx == nil {
+					log.Println("got nil index, skipping...")
 					continue
 				}
 
-				// s2 := time.Now()
-
-				nrd, err := nbt.NewRegionChunkReader(&chunk)
-				if err != nil {
+				ent := nrd.GetTileEntityDetails(idx)
+				if ent.Location.Dist(&anvil.Coord{
+					X: 235,
+					Y: 25,
+					Z: 73,
+				}) < 10 {
 					continue
-					// panic(err)
 				}
 
-				results := nrd.SimpleMatch([]byte("The Transreich Trade Agreement"), -1)
-				if len(results) == 0 {
-					continue
-				}
+				nrd.SeekTo(idx.Pos())
 
-				err = nrd.PrepareIndex(nbt.SelectiveIndex{
-					nbt.TagHeader{
-						TagID: nbt.TagList,
-						Name:  []byte("TileEntities"),
-					},
-				})
-				if err != nil {
-					panic(err)
-				}
+				if idx.TagID() == nbt.TagString {
+					var title string
+					nrd.ReadImmediate(nbt.TagString, &title)
+					fmt.Println("title:", title)
 
-				for _, res := range results {
-					nrd.SeekTo(res)
-					idx := nrd.AlignToIndex()
-					if idx == nil {
-						log.Println("got nil index, skipping...")
-						continue
-					}
-
-					ent := nrd.GetTileEntityDetails(idx)
-					if ent.Location.Dist(&anvil.Coord{
-						X: 235,
-						Y: 25,
-						Z: 73,
-					}) < 10 {
-						continue
-					}
-
-					nrd.SeekTo(idx.Pos)
-
-					if idx.Header.TagID == nbt.TagString {
-						var title string
-						nrd.ReadImmediate(nbt.TagString, &title)
-						fmt.Println("title:", title)
-
-						statsMutex.Lock()
-						stats[title]++
-						statsMutex.Unlock()
-					}
-
-					fmt.Printf("%+v\n", *ent)
+					statsMutex.Lock()
+					stats[title]++
+					statsMutex.Unlock()
 				}
+
+				fmt.Printf("%+v\n", *ent)
 			}
-		}()
+
+			return nil
+		})
+	if err != nil {
+		panic(err)
 	}
 
-	wg.Wait()
+	for _, err := range scanErrors {
+		log.Println(err)
+	}
 
 	log.Println("took:", time.Since(start))
 
@@ -0,0 +1,165 @@
+package anvil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CompactOptions are the options for CompactRegion.
+type CompactOptions struct {
+	// Recompress recompresses every chunk with the given compression type
+	// if it is set. Chunks are copied as is otherwise.
+	Recompress CompressionType
+
+	// Atomic are the options for the atomic region writer used to write the
+	// compacted region.
+	Atomic AtomicOptions
+}
+
+// CompactResult is the result of compacting a region file.
+type CompactResult struct {
+	Filename   string `json:"filename"`
+	Region     Region `json:"region"`
+	Chunks     int    `json:"chunks"`
+	SizeBefore int64  `json:"size_before"`
+	SizeAfter  int64  `json:"size_after"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Reclaimed returns the number of bytes reclaimed by compacting the region.
+func (r *CompactResult) Reclaimed() int64 {
+	return r.SizeBefore - r.SizeAfter
+}
+
+// CompactRegion rewrites a region file with its chunks packed contiguously
+// after the header, removing unused sectors. Chunks that fail to read abort the
+// compaction, use CheckRegion to repair the region first.
+func CompactRegion(filename string, opts CompactOptions) (*CompactResult, error) {
+	if opts.Recompress != 0 && !opts.Recompress.Valid() {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownCompression, opts.Recompress)
+	}
+
+	rd, err := OpenRegionFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+
+	result := &CompactResult{
+		Filename:   filename,
+		Region:     rd.Region,
+		SizeBefore: rd.size,
+	}
+
+	w, err := openRegionWriterAtomic(filename, opts.Atomic, false)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	for i := 0; i < 4096; i += 4 {
+		if rd.sectorOffset(i) == 0 {
+			continue
+		}
+
+		c, err := rd.readChunkData(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Recompress != 0 && opts.Recompress != c.Compression {
+			data, err := c.Decompress()
+			if err != nil {
+				return nil, err
+			}
+
+			c.Data, err = opts.Recompress.Compress(data)
+			if err != nil {
+				return nil, err
+			}
+			c.Compression = opts.Recompress
+		}
+
+		if err := w.WriteRawChunk(c); err != nil {
+			return nil, err
+		}
+
+		result.Chunks++
+	}
+
+	size := int64(len(w.used)) << sectorShift
+
+	if err := w.Commit(); err != nil {
+		return nil, err
+	}
+
+	result.SizeAfter = size
+
+	return result, nil
+}
+
+// CompactRegionDir compacts all of the region files in a directory using the
+// given number of workers. A result is returned for every region in the
+// directory in filename order, with the Error field set if the region failed
+// to compact.
+func CompactRegionDir(dir string, workers int, opts CompactOptions) ([]CompactResult, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var regionFiles []string
+
+	for _, file := range files {
+		if _, err := ParseRegionFilename(file.Name()); err == nil {
+			regionFiles = append(regionFiles, filepath.Join(dir, file.Name()))
+		}
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	in := make(chan int)
+	results := make([]CompactResult, len(regionFiles))
+
+	go func() {
+		defer close(in)
+		for i := range regionFiles {
+			in <- i
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range in {
+				result, err := CompactRegion(regionFiles[i], opts)
+				if err != nil {
+					result = &CompactResult{
+						Filename: regionFiles[i],
+						Error:    err.Error(),
+					}
+
+					if info, err := os.Stat(regionFiles[i]); err == nil {
+						result.SizeBefore = info.Size()
+						result.SizeAfter = info.Size()
+					}
+				}
+
+				results[i] = *result
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return results, nil
+}
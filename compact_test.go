@@ -0,0 +1,75 @@
+package anvil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactRegionDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c := Chunk{X: 0, Z: 0}, Chunk{X: 5, Z: 7}, Chunk{X: 9, Z: 9}
+
+	// c has no timestamp, which compacting must not give it
+	filename := filepath.Join(dir, "r.0.0.mca")
+	writeTestRegion(t, filename, []testChunk{
+		{a, CompressionZlib, zlibCompress([]byte("a")), 1},
+		{b, CompressionUncompressed, []byte("b"), 1},
+		{c, CompressionUncompressed, []byte("c"), 0},
+	})
+
+	// leave 3 dead sectors between the chunks
+	w, err := OpenRegionWriter(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Compression = CompressionUncompressed
+	assert.NoError(t, w.WriteChunk(a, make([]byte, 3*sectorSize)))
+	assert.NoError(t, w.WriteChunk(b, []byte("b")))
+	assert.NoError(t, w.WriteChunk(a, []byte("a")))
+	assert.NoError(t, w.Close())
+
+	err = ioutil.WriteFile(filepath.Join(dir, "r.1.0.mca"), []byte("corrupt"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := CompactRegionDir(dir, 4, CompactOptions{Recompress: CompressionGzip})
+	assert.NoError(t, err)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+
+	assert.Equal(t, "", results[0].Error)
+	assert.Equal(t, 3, results[0].Chunks)
+	assert.Equal(t, int64(9*sectorSize), results[0].SizeBefore)
+	assert.Equal(t, int64(5*sectorSize), results[0].SizeAfter)
+	assert.Equal(t, int64(4*sectorSize), results[0].Reclaimed())
+	assert.NotEqual(t, "", results[1].Error)
+
+	rd, err := OpenRegionFile(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rd.Close()
+
+	for chunk, expected := range map[Chunk]string{a: "a", b: "b", c: "c"} {
+		data, err := rd.ReadChunk(chunk)
+		assert.NoError(t, err)
+		assert.Equal(t, CompressionGzip, data.Compression)
+		decompressed, err := data.Decompress()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(decompressed))
+	}
+
+	assert.False(t, rd.LastModified(b).IsZero())
+	assert.True(t, rd.LastModified(c).IsZero())
+}
@@ -0,0 +1,299 @@
+package anvil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+)
+
+// CompressionType is the compression scheme byte stored in the header of
+// every chunk in a region file.
+type CompressionType byte
+
+const (
+	CompressionGzip         = CompressionType(1)
+	CompressionZlib         = CompressionType(2)
+	CompressionUncompressed = CompressionType(3)
+	CompressionLZ4          = CompressionType(4)
+)
+
+var (
+	ErrUnknownCompression = errors.New("anvil: unknown compression type")
+	ErrUnsupportedWrite   = errors.New("anvil: compression type is not supported for writing")
+)
+
+func (c CompressionType) String() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZlib:
+		return "zlib"
+	case CompressionUncompressed:
+		return "uncompressed"
+	case CompressionLZ4:
+		return "lz4"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(c))
+	}
+}
+
+// Valid returns whether the compression type is one that can be decoded.
+func (c CompressionType) Valid() bool {
+	return c >= CompressionGzip && c <= CompressionLZ4
+}
+
+// NewReader returns a reader which decompresses data according to the
+// compression type.
+func (c CompressionType) NewReader(rd io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewReader(rd)
+	case CompressionZlib:
+		return zlib.NewReader(rd)
+	case CompressionUncompressed:
+		return ioutil.NopCloser(rd), nil
+	case CompressionLZ4:
+		return ioutil.NopCloser(&lz4BlockReader{rd: rd}), nil
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownCompression, c)
+	}
+}
+
+// Decompress decompresses all of data according to the compression type.
+func (c CompressionType) Decompress(data []byte) ([]byte, error) {
+	if c == CompressionUncompressed {
+		return data, nil
+	}
+
+	rd, err := c.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return ioutil.ReadAll(rd)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// NewWriter returns a writer which compresses data according to the
+// compression type. LZ4 is not supported for writing.
+func (c CompressionType) NewWriter(wr io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewWriter(wr), nil
+	case CompressionZlib:
+		return zlib.NewWriter(wr), nil
+	case CompressionUncompressed:
+		return nopWriteCloser{wr}, nil
+	case CompressionLZ4:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedWrite, c)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownCompression, c)
+	}
+}
+
+// Compress compresses all of data according to the compression type.
+func (c CompressionType) Compress(data []byte) ([]byte, error) {
+	if c == CompressionUncompressed {
+		return data, nil
+	}
+
+	buf := new(bytes.Buffer)
+	wr, err := c.NewWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := wr.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := wr.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// lz4BlockReader reads the LZ4 block stream format used by Minecraft (as
+// written by lz4-java's LZ4BlockOutputStream). Each block has a 21 byte header
+// consisting of the "LZ4Block" magic, a token, the compressed length, the
+// decompressed length and a checksum. The stream ends with a block with a
+// length of 0. Checksums are not verified.
+type lz4BlockReader struct {
+	rd  io.Reader
+	buf []byte
+	eof bool
+}
+
+const (
+	lz4BlockHeaderSize = 21
+	lz4MethodRaw       = 0x10
+	lz4MethodLZ4       = 0x20
+)
+
+var lz4BlockMagic = []byte("LZ4Block")
+
+func (l *lz4BlockReader) Read(p []byte) (int, error) {
+	for len(l.buf) == 0 {
+		if l.eof {
+			return 0, io.EOF
+		}
+
+		if err := l.nextBlock(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, l.buf)
+	l.buf = l.buf[n:]
+	return n, nil
+}
+
+func (l *lz4BlockReader) nextBlock() error {
+	var header [lz4BlockHeaderSize]byte
+	_, err := io.ReadFull(l.rd, header[:])
+	if err == io.EOF {
+		// tolerate streams without an end block
+		l.eof = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(header[:8], lz4BlockMagic) {
+		return errors.New("anvil: invalid lz4 block magic")
+	}
+
+	// the low bits of the token give the block size the stream was written
+	// with, which is at most 32 MiB. Blocks claiming to be larger are
+	// corrupt, and are rejected before anything is allocated for them.
+	method := header[8] & 0xf0
+	blockSize := 1 << (10 + header[8]&0x0f)
+	compressedLen := int64(binary.LittleEndian.Uint32(header[9:13]))
+	decompressedLen := int(binary.LittleEndian.Uint32(header[13:17]))
+
+	if decompressedLen == 0 {
+		l.eof = true
+		return nil
+	}
+
+	// incompressible data is stored raw, so a valid block is never larger
+	// than the block size
+	if decompressedLen > blockSize || compressedLen > int64(blockSize) {
+		return fmt.Errorf("anvil: lz4 block lengths %d, %d exceed block size %d",
+			compressedLen, decompressedLen, blockSize)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, l.rd, compressedLen); err == io.EOF {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
+		return err
+	}
+	compressed := buf.Bytes()
+
+	switch method {
+	case lz4MethodRaw:
+		if len(compressed) != decompressedLen {
+			return errors.New("anvil: lz4 raw block length mismatch")
+		}
+		l.buf = compressed
+	case lz4MethodLZ4:
+		l.buf = make([]byte, decompressedLen)
+		if err := lz4DecodeBlock(compressed, l.buf); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("anvil: unknown lz4 block method 0x%02x", method)
+	}
+
+	return nil
+}
+
+var errLZ4Corrupt = errors.New("anvil: corrupt lz4 block")
+
+// lz4DecodeBlock decodes a raw LZ4 block from src into dst, which must be
+// exactly the size of the decompressed data.
+func lz4DecodeBlock(src, dst []byte) error {
+	si, di := 0, 0
+
+	readLength := func(length int) (int, bool) {
+		if length != 0xf {
+			return length, true
+		}
+
+		for si < len(src) {
+			b := src[si]
+			si++
+			length += int(b)
+			if b != 0xff {
+				return length, true
+			}
+		}
+
+		return 0, false
+	}
+
+	for si < len(src) {
+		token := src[si]
+		si++
+
+		literals, ok := readLength(int(token >> 4))
+		if !ok || si+literals > len(src) || di+literals > len(dst) {
+			return errLZ4Corrupt
+		}
+
+		di += copy(dst[di:], src[si:si+literals])
+		si += literals
+
+		// the last sequence only contains literals
+		if si == len(src) {
+			break
+		}
+
+		if si+2 > len(src) {
+			return errLZ4Corrupt
+		}
+
+		offset := int(src[si]) | int(src[si+1])<<8
+		si += 2
+		if offset == 0 || offset > di {
+			return errLZ4Corrupt
+		}
+
+		matchLen, ok := readLength(int(token & 0xf))
+		if !ok {
+			return errLZ4Corrupt
+		}
+		matchLen += 4
+
+		if di+matchLen > len(dst) {
+			return errLZ4Corrupt
+		}
+
+		// copy byte by byte as the match may overlap with itself
+		for i := 0; i < matchLen; i++ {
+			dst[di] = dst[di-offset]
+			di++
+		}
+	}
+
+	if di != len(dst) {
+		return errLZ4Corrupt
+	}
+
+	return nil
+}
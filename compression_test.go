@@ -0,0 +1,80 @@
+package anvil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func lz4Block(method byte, compressed []byte, decompressedLen int) []byte {
+	header := make([]byte, lz4BlockHeaderSize)
+	copy(header, lz4BlockMagic)
+	header[8] = method
+	binary.LittleEndian.PutUint32(header[9:], uint32(len(compressed)))
+	binary.LittleEndian.PutUint32(header[13:], uint32(decompressedLen))
+	return append(header, compressed...)
+}
+
+func TestDecompress(t *testing.T) {
+	expected := []byte("abcabcabcabcX")
+
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(expected)
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write(expected)
+	zw.Close()
+
+	var lz4 []byte
+	lz4 = append(lz4, lz4Block(lz4MethodLZ4,
+		[]byte{0x35, 'a', 'b', 'c', 0x03, 0x00, 0x10, 'X'}, len(expected))...)
+	lz4 = append(lz4, lz4Block(lz4MethodRaw, []byte("raw"), 3)...)
+	lz4 = append(lz4, lz4Block(lz4MethodRaw, nil, 0)...)
+
+	tests := []struct {
+		compression CompressionType
+		data        []byte
+		expected    []byte
+	}{
+		{CompressionGzip, gz.Bytes(), expected},
+		{CompressionZlib, zl.Bytes(), expected},
+		{CompressionUncompressed, expected, expected},
+		{CompressionLZ4, lz4, append(append([]byte{}, expected...), "raw"...)},
+	}
+
+	for _, test := range tests {
+		c := ChunkData{Compression: test.compression, Data: test.data}
+		result, err := c.Decompress()
+		assert.NoError(t, err, test.compression)
+		assert.Equal(t, test.expected, result, test.compression)
+	}
+
+	c := ChunkData{Compression: CompressionType(9), Data: expected}
+	_, err := c.Decompress()
+	assert.Error(t, err)
+
+	// blocks larger than the stream's block size, which is 1 KiB for a token
+	// of 0, are rejected without reading them
+	oversized := [][]byte{
+		lz4Block(lz4MethodLZ4, []byte{0x35, 'a', 'b', 'c'}, 0xffffffff),
+		lz4Block(lz4MethodRaw, nil, 1025),
+		lz4Block(lz4MethodRaw|0x0f, nil, 1<<25+1),
+	}
+	binary.LittleEndian.PutUint32(oversized[1][9:], 0xfffffff0)
+
+	for _, block := range oversized {
+		c := ChunkData{Compression: CompressionLZ4, Data: block}
+		_, err := c.Decompress()
+		assert.Error(t, err)
+	}
+
+	// as are truncated blocks
+	c = ChunkData{Compression: CompressionLZ4, Data: lz4[:lz4BlockHeaderSize+4]}
+	_, err = c.Decompress()
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+package anvil
+
+import (
+	"errors"
+	"os"
+	"sort"
+)
+
+// RegionKind is the kind of chunks stored in a region folder. Since Minecraft
+// 1.17 entities are stored separately from terrain in the entities folder, and
+// since 1.14 points of interest such as beds and job sites in the poi folder.
+// Both use the same region file format as terrain.
+type RegionKind int
+
+const (
+	RegionTerrain = RegionKind(iota)
+	RegionEntities
+	RegionPOI
+)
+
+func (k RegionKind) String() string {
+	switch k {
+	case RegionEntities:
+		return "entities"
+	case RegionPOI:
+		return "poi"
+	default:
+		return "region"
+	}
+}
+
+// regionFolderKind returns the kind of chunks in region files in the folder
+// with the given name, or false if it isn't a region folder.
+func regionFolderKind(name string) (RegionKind, bool) {
+	switch name {
+	case "region":
+		return RegionTerrain, true
+	case "entities":
+		return RegionEntities, true
+	case "poi":
+		return RegionPOI, true
+	default:
+		return 0, false
+	}
+}
+
+// Views returns the dimension followed by its entities and poi views, see
+// Entities and POI, omitting the folders it doesn't have.
+func (d *Dimension) Views() []*Dimension {
+	var views []*Dimension
+	for _, view := range d.kindViews() {
+		if view != nil {
+			views = append(views, view)
+		}
+	}
+	return views
+}
+
+// Entities returns a view of the dimension's entities folder, or nil if it
+// doesn't have one. All of the methods of Dimension can be used on the
+// returned dimension to read entity chunks.
+func (d *Dimension) Entities() *Dimension {
+	return d.kindView(RegionEntities, d.EntitiesDir)
+}
+
+// POI returns a view of the dimension's poi folder, or nil if it doesn't have
+// one, see Entities.
+func (d *Dimension) POI() *Dimension {
+	return d.kindView(RegionPOI, d.POIDir)
+}
+
+func (d *Dimension) kindView(kind RegionKind, dir string) *Dimension {
+	if dir == "" {
+		return nil
+	}
+
+	return &Dimension{
+		Name:      d.Name,
+		Dir:       d.Dir,
+		RegionDir: dir,
+		Format:    regionDirFormat(dir),
+		Kind:      kind,
+	}
+}
+
+// ChunkSet is a terrain chunk joined with the entity and POI chunks at the same
+// coordinates. Parts which aren't present have no Data.
+type ChunkSet struct {
+	Chunk     Chunk
+	Dimension string
+	Terrain   ChunkData
+	Entities  ChunkData
+	POI       ChunkData
+}
+
+// ReadChunkSet reads the terrain, entity and POI chunks at the given chunk
+// coordinates. A chunk not being present in any of the folders is not an
+// error.
+func (d *Dimension) ReadChunkSet(chunk Chunk) (ChunkSet, error) {
+	set := ChunkSet{
+		Chunk:     chunk,
+		Dimension: d.Name,
+	}
+
+	for _, view := range d.kindViews() {
+		if view == nil {
+			continue
+		}
+
+		c, err := view.ReadChunk(chunk)
+		if errors.Is(err, ErrChunkNotPresent) {
+			continue
+		} else if err != nil {
+			return set, err
+		}
+
+		*set.part(view.Kind) = c
+	}
+
+	return set, nil
+}
+
+// ReadRegionSets reads the chunk sets of every chunk in the given region which
+// is present in any of the dimension's terrain, entities or poi folders,
+// ordered by their position in the region. Each region file is only opened
+// once, so this is much faster than calling ReadChunkSet for every chunk.
+func (d *Dimension) ReadRegionSets(region Region) ([]ChunkSet, error) {
+	sets := make(map[Chunk]*ChunkSet)
+
+	for _, view := range d.kindViews() {
+		if view == nil {
+			continue
+		}
+
+		if err := view.readRegionSets(region, d.Name, sets); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]ChunkSet, 0, len(sets))
+	for _, set := range sets {
+		results = append(results, *set)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Chunk.RegionChunkOffset() < results[j].Chunk.RegionChunkOffset()
+	})
+
+	return results, nil
+}
+
+func (d *Dimension) readRegionSets(region Region, name string, sets map[Chunk]*ChunkSet) error {
+	rd, err := d.OpenRegion(region)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	for _, chunk := range rd.Chunks() {
+		c, err := rd.ReadChunk(chunk)
+		if err != nil {
+			return err
+		}
+
+		c.Dimension = name
+		c.Kind = d.Kind
+
+		set, found := sets[chunk]
+		if !found {
+			set = &ChunkSet{Chunk: chunk, Dimension: name}
+			sets[chunk] = set
+		}
+
+		*set.part(d.Kind) = c
+	}
+
+	return nil
+}
+
+// kindViews returns the dimension's terrain, entities and poi views, which
+// are nil if the dimension doesn't have the folder.
+func (d *Dimension) kindViews() [3]*Dimension {
+	return [3]*Dimension{d, d.Entities(), d.POI()}
+}
+
+func (s *ChunkSet) part(kind RegionKind) *ChunkData {
+	switch kind {
+	case RegionEntities:
+		return &s.Entities
+	case RegionPOI:
+		return &s.POI
+	default:
+		return &s.Terrain
+	}
+}
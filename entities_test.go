@@ -0,0 +1,105 @@
+package anvil
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntitiesAndPOI(t *testing.T) {
+	dir := writeTestWorld(t, map[string][]testChunk{
+		"region/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("terrain 0"), 0},
+			{Chunk{X: 1, Z: 0}, CompressionUncompressed, []byte("terrain 1"), 0},
+		},
+		"entities/r.0.0.mca": {
+			{Chunk{X: 1, Z: 0}, CompressionUncompressed, []byte("entities 1"), 0},
+			{Chunk{X: 2, Z: 0}, CompressionUncompressed, []byte("entities 2"), 0},
+		},
+		"poi/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("poi 0"), 0},
+		},
+		"DIM-1/region/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("nether"), 0},
+		},
+	}, "level.dat")
+	defer os.RemoveAll(dir)
+
+	w, err := OpenWorld(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	overworld := w.Dimension(Overworld)
+	assert.Nil(t, w.Dimension(Nether).Entities())
+	assert.Nil(t, w.Dimension(Nether).POI())
+
+	entities := overworld.Entities()
+	if !assert.NotNil(t, entities) {
+		return
+	}
+
+	c, err := entities.ReadChunk(Chunk{X: 2, Z: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("entities 2"), c.Data)
+	assert.Equal(t, RegionEntities, c.Kind)
+	assert.Equal(t, Overworld, c.Dimension)
+
+	set, err := overworld.ReadChunkSet(Chunk{X: 0, Z: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("terrain 0"), set.Terrain.Data)
+	assert.Nil(t, set.Entities.Data)
+	assert.Equal(t, []byte("poi 0"), set.POI.Data)
+	assert.Equal(t, RegionPOI, set.POI.Kind)
+
+	sets, err := overworld.ReadRegionSets(Region{X: 0, Z: 0})
+	assert.NoError(t, err)
+	if assert.Len(t, sets, 3) {
+		assert.Equal(t, Chunk{X: 0, Z: 0}, sets[0].Chunk)
+		assert.Equal(t, []byte("terrain 1"), sets[1].Terrain.Data)
+		assert.Equal(t, []byte("entities 1"), sets[1].Entities.Data)
+		assert.Nil(t, sets[2].Terrain.Data)
+		assert.Equal(t, []byte("entities 2"), sets[2].Entities.Data)
+	}
+
+	mutex := new(sync.Mutex)
+	var kinds []RegionKind
+	_, err = (&Scanner{}).ScanDimension(context.Background(), overworld.POI(),
+		func(c ChunkData) error {
+			mutex.Lock()
+			kinds = append(kinds, c.Kind)
+			mutex.Unlock()
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, []RegionKind{RegionPOI}, kinds)
+
+	assert.Len(t, overworld.Views(), 3)
+	assert.Len(t, w.Dimension(Nether).Views(), 1)
+
+	// world-wide iteration includes entity and POI chunks
+	counts := make(map[RegionKind]int)
+	it := w.Iterate(nil)
+	for it.Next() {
+		assert.NoError(t, it.Err())
+		counts[it.Chunk().Kind]++
+	}
+	assert.NoError(t, it.Close())
+	assert.Equal(t, map[RegionKind]int{RegionTerrain: 3, RegionEntities: 2, RegionPOI: 1},
+		counts)
+
+	results := make(chan ChunkData, 10)
+	assert.NoError(t, w.ReadAllChunks(results))
+	close(results)
+
+	var entityData []string
+	for c := range results {
+		if c.Kind == RegionEntities {
+			entityData = append(entityData, string(c.Data))
+		}
+	}
+	assert.Equal(t, []string{"entities 1", "entities 2"}, entityData)
+}
@@ -0,0 +1,41 @@
+package anvil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors for chunks which couldn't be read, returned wrapped in a ChunkError so
+// they can be checked with errors.Is. Chunks with unsupported compression
+// types return ErrUnknownCompression.
+var (
+	ErrChunkNotPresent  = errors.New("anvil: chunk not present")
+	ErrSectorOutOfRange = errors.New("anvil: chunk sector out of range")
+	ErrLengthMismatch   = errors.New("anvil: chunk length doesn't match region file")
+	ErrDecompression    = errors.New("anvil: failed to decompress chunk")
+)
+
+// ChunkError is an error reading or decompressing a chunk.
+type ChunkError struct {
+	Region Region
+	Chunk  Chunk
+	Err    error
+}
+
+func newChunkError(chunk Chunk, err error) *ChunkError {
+	return &ChunkError{
+		Region: chunk.Region(),
+		Chunk:  chunk,
+		Err:    err,
+	}
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("anvil: chunk %d, %d in region %d, %d: %s", e.Chunk.X, e.Chunk.Z,
+		e.Region.X, e.Region.Z, strings.TrimPrefix(e.Err.Error(), "anvil: "))
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,83 @@
+package anvil
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkErrors(t *testing.T) {
+	blob := make([]byte, headerSize+3*sectorSize)
+
+	setLocation := func(chunk Chunk, sector int) {
+		offset := chunk.RegionChunkOffset()
+		blob[offset], blob[offset+1], blob[offset+2], blob[offset+3] =
+			byte(sector>>16), byte(sector>>8), byte(sector), 1
+	}
+
+	setChunk := func(sector, length int, compression CompressionType, data []byte) {
+		pos := sector << sectorShift
+		blob[pos], blob[pos+1], blob[pos+2], blob[pos+3], blob[pos+4] =
+			byte(length>>24), byte(length>>16), byte(length>>8), byte(length),
+			byte(compression)
+		copy(blob[pos+5:], data)
+	}
+
+	setLocation(Chunk{X: 0, Z: 0}, 1)
+	setLocation(Chunk{X: 1, Z: 0}, 100)
+	setLocation(Chunk{X: 2, Z: 0}, 2)
+	setChunk(2, 0, CompressionZlib, nil)
+	setLocation(Chunk{X: 3, Z: 0}, 3)
+	setChunk(3, 2*sectorSize, CompressionZlib, nil)
+	setLocation(Chunk{X: 5, Z: 0}, 4)
+	setChunk(4, 6, CompressionType(9), []byte("abcde"))
+
+	rd, err := NewRegionReader(bytes.NewReader(blob), int64(len(blob)), Region{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = rd.ReadChunk(Chunk{X: 0, Z: 1})
+	assert.True(t, errors.Is(err, ErrChunkNotPresent))
+
+	_, err = rd.ReadChunk(Chunk{X: 0, Z: 0})
+	assert.True(t, errors.Is(err, ErrSectorOutOfRange))
+
+	_, err = rd.ReadChunk(Chunk{X: 1, Z: 0})
+	assert.True(t, errors.Is(err, ErrSectorOutOfRange))
+	assert.Equal(t, "anvil: chunk 1, 0 in region 0, 0: chunk sector out of range: "+
+		"sector 100, region has 5", err.Error())
+
+	_, err = rd.ReadChunk(Chunk{X: 2, Z: 0})
+	assert.True(t, errors.Is(err, ErrLengthMismatch))
+
+	_, err = rd.ReadChunk(Chunk{X: 3, Z: 0})
+	assert.True(t, errors.Is(err, ErrLengthMismatch))
+
+	c, err := rd.ReadChunk(Chunk{X: 5, Z: 0})
+	assert.NoError(t, err)
+	_, err = c.Decompress()
+	assert.True(t, errors.Is(err, ErrUnknownCompression))
+
+	c.Compression = CompressionZlib
+	_, err = c.Decompress()
+	assert.True(t, errors.Is(err, ErrDecompression))
+
+	c.Compression = CompressionGzip
+	c.Data = gzipPrefix
+	r, err := c.NewReader()
+	if assert.NoError(t, err) {
+		_, err = ioutil.ReadAll(r)
+		assert.True(t, errors.Is(err, ErrDecompression))
+
+		var chunkErr *ChunkError
+		assert.True(t, errors.As(err, &chunkErr))
+		assert.Equal(t, Chunk{X: 5, Z: 0}, chunkErr.Chunk)
+	}
+}
+
+// gzipPrefix is the header of a gzip stream with no body.
+var gzipPrefix = []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 0xff}
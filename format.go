@@ -0,0 +1,89 @@
+package anvil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// RegionFormat is the format of a region file. Anvil and McRegion files share
+// the same file layout but store chunks differently, while .linear files store
+// Anvil chunks in a different file layout.
+type RegionFormat int
+
+const (
+	// FormatAnvil is the .mca format used since Minecraft 1.2.
+	FormatAnvil = RegionFormat(iota)
+	// FormatMcRegion is the .mcr format used before Minecraft 1.2. Its chunks
+	// are McRegionHeight blocks high and store their blocks in the Level's
+	// Blocks and Data byte arrays instead of in sections.
+	FormatMcRegion
+	// FormatLinear is the zstd compressed .linear format used by some server
+	// forks, storing Anvil chunks. It can only be read.
+	FormatLinear
+)
+
+// McRegionHeight is the height of chunks in McRegion worlds.
+const McRegionHeight = 128
+
+func (f RegionFormat) String() string {
+	switch f {
+	case FormatMcRegion:
+		return "mcregion"
+	case FormatLinear:
+		return "linear"
+	default:
+		return "anvil"
+	}
+}
+
+// Extension returns the extension of region files of the format, including the
+// dot.
+func (f RegionFormat) Extension() string {
+	switch f {
+	case FormatMcRegion:
+		return ".mcr"
+	case FormatLinear:
+		return ".linear"
+	default:
+		return ".mca"
+	}
+}
+
+// regionFileFormat returns the format of a region file from its extension.
+func regionFileFormat(filename string) RegionFormat {
+	switch filepath.Ext(filename) {
+	case ".mcr":
+		return FormatMcRegion
+	case ".linear":
+		return FormatLinear
+	default:
+		return FormatAnvil
+	}
+}
+
+// regionDirFormat returns the format of the region files in a region folder.
+// Minecraft leaves the .mcr files in place when converting a world to Anvil,
+// so .mca files take precedence, followed by .linear files.
+func regionDirFormat(dir string) RegionFormat {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return FormatAnvil
+	}
+
+	format := FormatAnvil
+
+	for _, file := range files {
+		switch filepath.Ext(file.Name()) {
+		case ".mca":
+			return FormatAnvil
+		case ".linear":
+			format = FormatLinear
+		case ".mcr":
+			if format != FormatLinear {
+				format = FormatMcRegion
+			}
+		}
+	}
+
+	return format
+}
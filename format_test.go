@@ -0,0 +1,59 @@
+package anvil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMcRegion(t *testing.T) {
+	chunks := []testChunk{
+		{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("mcr"), 0},
+	}
+
+	dir := writeTestWorld(t, map[string][]testChunk{
+		"region/r.0.0.mcr":       chunks,
+		"region/r.-1.0.mcr":      chunks,
+		"DIM-1/region/r.0.0.mcr": chunks,
+		"DIM-1/region/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("mca"), 0},
+		},
+	}, "level.dat")
+	defer os.RemoveAll(dir)
+
+	rd, err := OpenRegionFile(filepath.Join(dir, "region", "r.0.0.mcr"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c, err := rd.ReadChunk(Chunk{X: 0, Z: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, FormatMcRegion, c.Format)
+	assert.Equal(t, []byte("mcr"), c.Data)
+	assert.NoError(t, rd.Close())
+
+	w, err := OpenWorld(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	overworld := w.Dimension(Overworld)
+	assert.Equal(t, FormatMcRegion, overworld.Format)
+	regions, err := overworld.Regions()
+	assert.NoError(t, err)
+	assert.Equal(t, []Region{{X: -1, Z: 0}, {X: 0, Z: 0}}, regions)
+
+	c, err = overworld.ReadChunk(Chunk{X: 0, Z: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, FormatMcRegion, c.Format)
+
+	// converted worlds still have their .mcr files
+	nether := w.Dimension(Nether)
+	assert.Equal(t, FormatAnvil, nether.Format)
+	c, err = nether.ReadChunk(Chunk{X: 0, Z: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, FormatAnvil, c.Format)
+	assert.Equal(t, []byte("mca"), c.Data)
+}
@@ -0,0 +1,352 @@
+package anvil
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ProblemKind is the kind of problem found in a region file by CheckRegion.
+type ProblemKind string
+
+const (
+	// ProblemSectorOutOfRange is a chunk whose location points inside the
+	// region's header.
+	ProblemSectorOutOfRange = ProblemKind("sector_out_of_range")
+	// ProblemPastEOF is a chunk whose location or data extends past the end
+	// of the region file.
+	ProblemPastEOF = ProblemKind("past_eof")
+	// ProblemLengthExceedsSectors is a chunk whose length is larger than
+	// the number of sectors allocated to it in the location table.
+	ProblemLengthExceedsSectors = ProblemKind("length_exceeds_sectors")
+	// ProblemOverlap is a chunk whose sectors are also used by another
+	// chunk.
+	ProblemOverlap = ProblemKind("overlap")
+	// ProblemUnknownCompression is a chunk with an unknown compression type.
+	ProblemUnknownCompression = ProblemKind("unknown_compression")
+	// ProblemExternalMissing is an external chunk whose .mcc file can't be
+	// read.
+	ProblemExternalMissing = ProblemKind("external_missing")
+	// ProblemDecompression is a chunk whose data failed to decompress.
+	ProblemDecompression = ProblemKind("decompression_failed")
+	// ProblemInvalidNBT is a chunk whose data failed validation.
+	ProblemInvalidNBT = ProblemKind("invalid_nbt")
+)
+
+// RepairAction is the action taken to repair a problem.
+type RepairAction string
+
+const (
+	RepairActionNone      = RepairAction("")
+	RepairActionDropped   = RepairAction("dropped")
+	RepairActionRelocated = RepairAction("relocated")
+)
+
+// RepairMode determines how CheckRegion repairs problems it finds.
+type RepairMode int
+
+const (
+	// RepairNone only reports problems.
+	RepairNone = RepairMode(iota)
+	// RepairDrop removes every chunk with a problem from the region.
+	RepairDrop
+	// RepairRelocate moves chunks with overlapping sectors or lengths
+	// exceeding their sectors to their own sectors if their data is still
+	// intact, and drops all other chunks with problems.
+	RepairRelocate
+)
+
+// CheckOptions are the options for CheckRegion.
+type CheckOptions struct {
+	// HeaderOnly skips reading chunk data, so only problems with the
+	// location table are found. Chunks with overlapping sectors are only
+	// reported and not repaired, since which of them is intact can't be
+	// told without reading their data.
+	HeaderOnly bool
+
+	// Validate is called with the decompressed data of every chunk to
+	// validate it. nbt.Validate can be used to check that it parses.
+	Validate func(data []byte) error
+
+	// Repair is the repair mode. Repairs are made with an atomic region
+	// writer using the Atomic options.
+	Repair RepairMode
+	Atomic AtomicOptions
+}
+
+// Problem is a problem with a chunk found by CheckRegion.
+type Problem struct {
+	Chunk  Chunk        `json:"chunk"`
+	Kind   ProblemKind  `json:"kind"`
+	Detail string       `json:"detail,omitempty"`
+	Action RepairAction `json:"action,omitempty"`
+}
+
+// CheckReport is the result of checking a region file.
+type CheckReport struct {
+	Filename string    `json:"filename"`
+	Region   Region    `json:"region"`
+	Size     int64     `json:"size"`
+	Chunks   int       `json:"chunks"`
+	Problems []Problem `json:"problems"`
+	Repaired bool      `json:"repaired"`
+}
+
+// OK returns whether the region has no problems.
+func (r *CheckReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+type chunkCheck struct {
+	offset    int
+	chunk     Chunk
+	start     int
+	count     int
+	problems  []Problem
+	data      ChunkData
+	readable  bool
+	relocates bool
+
+	// unrepaired is set for chunks which are only reported, as repairing
+	// them could keep corrupt data.
+	unrepaired bool
+}
+
+// CheckRegion checks the integrity of a region file, and optionally repairs
+// it. Problems with chunks are reported in the returned report, an error is
+// only returned if the region can't be checked at all.
+func CheckRegion(filename string, opts CheckOptions) (*CheckReport, error) {
+	rd, err := OpenRegionFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+
+	if rd.linear != nil {
+		return nil, ErrLinearRegion
+	}
+
+	report := &CheckReport{
+		Filename: filename,
+		Region:   rd.Region,
+		Size:     rd.size,
+		Problems: []Problem{},
+	}
+
+	checks := rd.checkChunks(rd.size, opts)
+	for _, c := range checks {
+		report.Chunks++
+		report.Problems = append(report.Problems, c.problems...)
+	}
+
+	if opts.Repair == RepairNone || report.OK() {
+		return report, nil
+	}
+
+	if err := repairRegion(filename, checks, report, opts); err != nil {
+		return report, fmt.Errorf("anvil: failed to repair region: %w", err)
+	}
+
+	return report, nil
+}
+
+func (r *RegionReader) checkChunks(size int64, opts CheckOptions) []*chunkCheck {
+	var checks []*chunkCheck
+
+	for i := 0; i < 4096; i += 4 {
+		start := r.sectorOffset(i)
+		if start == 0 {
+			continue
+		}
+
+		c := &chunkCheck{
+			offset: i,
+			chunk:  r.Region.OffsetToChunk(i),
+			start:  start,
+			count:  r.sectorCount(i),
+		}
+		checks = append(checks, c)
+
+		if start < headerSize>>sectorShift {
+			c.addProblem(ProblemSectorOutOfRange, "chunk starts at sector %d", start)
+			continue
+		}
+
+		r.checkChunkData(c, size, opts)
+	}
+
+	for _, a := range checks {
+		for _, b := range checks {
+			if a == b || a.start < headerSize>>sectorShift ||
+				b.start < headerSize>>sectorShift {
+				continue
+			}
+
+			if a.start < b.start+b.count && b.start < a.start+a.count {
+				a.addProblem(ProblemOverlap, "sectors overlap with chunk %d, %d",
+					b.chunk.X, b.chunk.Z)
+
+				if opts.HeaderOnly {
+					// without checking their data there's no telling which
+					// of the chunks is intact, so leave both of them alone
+					a.relocates = false
+					a.unrepaired = true
+				} else {
+					a.relocates = a.readable
+				}
+			}
+		}
+	}
+
+	return checks
+}
+
+func (r *RegionReader) checkChunkData(c *chunkCheck, size int64, opts CheckOptions) {
+	pos := int64(c.start) << sectorShift
+	if pos+5 > size {
+		c.addProblem(ProblemPastEOF, "chunk starts at sector %d, file has %d sectors",
+			c.start, (size+sectorSize-1)>>sectorShift)
+		return
+	}
+
+	var chunkHeader [5]byte
+	if err := r.readAt(chunkHeader[:], pos); err != nil {
+		c.addProblem(ProblemPastEOF, "failed to read chunk header: %v", err)
+		return
+	}
+
+	length := int64(chunkHeader[0])<<24 | int64(chunkHeader[1])<<16 |
+		int64(chunkHeader[2])<<8 | int64(chunkHeader[3])
+	compression := CompressionType(chunkHeader[4] &^ externalFlag)
+
+	if pos+4+length > size {
+		c.addProblem(ProblemPastEOF, "chunk length %d extends past end of file", length)
+		return
+	}
+
+	exceeds := length+4 > int64(c.count)<<sectorShift
+	if exceeds {
+		c.addProblem(ProblemLengthExceedsSectors, "chunk length %d exceeds %d sectors",
+			length, c.count)
+	}
+
+	if !compression.Valid() {
+		c.addProblem(ProblemUnknownCompression, "compression type %d", compression)
+		return
+	}
+
+	if opts.HeaderOnly {
+		c.readable = true
+		c.relocates = exceeds
+		return
+	}
+
+	data, err := r.readChunkData(c.offset)
+	if err != nil {
+		if data.External {
+			c.addProblem(ProblemExternalMissing, "%v", err)
+		} else {
+			c.addProblem(ProblemPastEOF, "%v", err)
+		}
+		return
+	}
+
+	decompressed, err := data.Decompress()
+	if err != nil {
+		c.addProblem(ProblemDecompression, "%v", err)
+		return
+	}
+
+	if opts.Validate != nil {
+		if err := opts.Validate(decompressed); err != nil {
+			c.addProblem(ProblemInvalidNBT, "%v", err)
+			return
+		}
+	}
+
+	c.data = data
+	c.readable = true
+	c.relocates = exceeds
+}
+
+func (c *chunkCheck) addProblem(kind ProblemKind, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{
+		Chunk:  c.chunk,
+		Kind:   kind,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+func repairRegion(filename string, checks []*chunkCheck, report *CheckReport,
+	opts CheckOptions) error {
+	w, err := OpenRegionWriterAtomic(filename, opts.Atomic)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	actions := make(map[Chunk]RepairAction)
+	var relocate []ChunkData
+
+	// drop every chunk with problems first so their sectors are freed,
+	// then write back the chunks being relocated
+	for _, c := range checks {
+		if len(c.problems) == 0 || c.unrepaired {
+			continue
+		}
+
+		if opts.Repair == RepairRelocate && c.relocates {
+			data := c.data
+			if data.Data == nil {
+				data, err = w.readChunkData(c.offset)
+				if err != nil {
+					return err
+				}
+			}
+
+			relocate = append(relocate, data)
+			actions[c.chunk] = RepairActionRelocated
+		} else {
+			actions[c.chunk] = RepairActionDropped
+		}
+
+		// DeleteChunk can't be used as it would free sectors of
+		// chunks without problems that these chunks overlap with
+		w.markSectors(c.start, c.count, false)
+		if err := w.writeHeader(c.offset, 0, 0, time.Time{}); err != nil {
+			return err
+		}
+
+		if actions[c.chunk] == RepairActionDropped {
+			path := filepath.Join(w.dir, ExternalChunkFilename(c.chunk))
+			if err := w.writeExternal(path, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	// chunks that overlap each other were freed above, but their sectors
+	// may also be claimed by chunks without problems
+	for _, c := range checks {
+		if len(c.problems) == 0 || c.unrepaired {
+			w.markSectors(c.start, c.count, true)
+		}
+	}
+
+	for _, data := range relocate {
+		if err := w.WriteRawChunk(data); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Commit(); err != nil {
+		return err
+	}
+
+	for i := range report.Problems {
+		report.Problems[i].Action = actions[report.Problems[i].Chunk]
+	}
+	report.Repaired = true
+
+	return nil
+}
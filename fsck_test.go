@@ -0,0 +1,132 @@
+package anvil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRegion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c, d := Chunk{X: 0, Z: 0}, Chunk{X: 1, Z: 0}, Chunk{X: 2, Z: 0}, Chunk{X: 3, Z: 0}
+
+	filename := filepath.Join(dir, "r.0.0.mca")
+	writeTestRegion(t, filename, []testChunk{
+		{a, CompressionZlib, zlibCompress([]byte("a")), 1},
+		{b, CompressionZlib, zlibCompress([]byte("b")), 1},
+		{c, CompressionType(9), []byte("c"), 1},
+		{d, CompressionUncompressed, []byte("d"), 1},
+	})
+
+	region, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a claims b's sector as well as its own, and d points past the end
+	region[a.RegionChunkOffset()+3] = 2
+	region[d.RegionChunkOffset()+2] = 100
+
+	if err := ioutil.WriteFile(filename, region, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := CheckRegion(filename, CheckOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Chunks)
+	assert.False(t, report.Repaired)
+
+	kinds := make(map[Chunk][]ProblemKind)
+	for _, p := range report.Problems {
+		kinds[p.Chunk] = append(kinds[p.Chunk], p.Kind)
+	}
+
+	assert.Equal(t, map[Chunk][]ProblemKind{
+		a: {ProblemOverlap},
+		b: {ProblemOverlap},
+		c: {ProblemUnknownCompression},
+		d: {ProblemPastEOF},
+	}, kinds)
+
+	// without reading the data, overlapping chunks are left alone
+	report, err = CheckRegion(filename, CheckOptions{
+		HeaderOnly: true,
+		Repair:     RepairRelocate,
+	})
+	assert.NoError(t, err)
+	assert.True(t, report.Repaired)
+
+	actions := make(map[Chunk]RepairAction)
+	for _, p := range report.Problems {
+		actions[p.Chunk] = p.Action
+	}
+
+	assert.Equal(t, map[Chunk]RepairAction{
+		a: RepairActionNone,
+		b: RepairActionNone,
+		c: RepairActionDropped,
+		d: RepairActionDropped,
+	}, actions)
+
+	report, err = CheckRegion(filename, CheckOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Chunks)
+	assert.Len(t, report.Problems, 2)
+
+	if err := ioutil.WriteFile(filename, region, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// reject chunk b's data so only chunk a is relocated
+	validate := func(data []byte) error {
+		if string(data) == "b" {
+			return ErrUnknownCompression
+		}
+		return nil
+	}
+
+	report, err = CheckRegion(filename, CheckOptions{
+		Validate: validate,
+		Repair:   RepairRelocate,
+	})
+	assert.NoError(t, err)
+	assert.True(t, report.Repaired)
+
+	actions = make(map[Chunk]RepairAction)
+	for _, p := range report.Problems {
+		actions[p.Chunk] = p.Action
+	}
+
+	assert.Equal(t, map[Chunk]RepairAction{
+		a: RepairActionRelocated,
+		b: RepairActionDropped,
+		c: RepairActionDropped,
+		d: RepairActionDropped,
+	}, actions)
+
+	report, err = CheckRegion(filename, CheckOptions{Validate: validate})
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, 1, report.Chunks)
+
+	rd, err := OpenRegionFile(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rd.Close()
+
+	data, err := rd.ReadChunk(a)
+	assert.NoError(t, err)
+	decompressed, err := data.Decompress()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(decompressed))
+	assert.Equal(t, int64(1), data.LastModified.Unix())
+}
@@ -7,7 +7,6 @@ require (
 	github.com/midnightfreddie/nbt2json v0.3.4 // indirect
 	github.com/minio/highwayhash v1.0.0
 	github.com/philhofer/fwd v1.0.0 // indirect
-	github.com/ppacher/nbt v0.0.0-20181201174858-0cad976cf07c
 	github.com/stretchr/testify v1.5.1
 	github.com/tinylib/msgp v1.1.2
 	github.com/ttacon/chalk v0.0.0-20160626202418-22c06c80ed31 // indirect
@@ -0,0 +1,191 @@
+package anvil
+
+import "fmt"
+
+// ChunkIterator iterates over the chunks of a region, dimension or world
+// without any goroutines, so iteration can be stopped at any point. Errors
+// reading individual chunks don't stop the iteration, so corrupted chunks can
+// be skipped:
+//
+//	it := dim.Iterate(nil)
+//	defer it.Close()
+//
+//	for it.Next() {
+//		if it.Err() != nil {
+//			log.Println(it.Err())
+//			continue
+//		}
+//
+//		c := it.Chunk()
+//		...
+//	}
+//
+//	if it.Err() != nil {
+//		...
+//	}
+type ChunkIterator struct {
+	sel Selector
+
+	// regions are the region files left to iterate over, and rd the region
+	// currently being iterated over.
+	regions   []iterRegion
+	rd        *RegionReader
+	closeRd   bool
+	dimension *Dimension
+	chunks    []Chunk
+
+	chunk ChunkData
+	err   error
+	done  bool
+}
+
+type iterRegion struct {
+	dimension *Dimension
+	region    Region
+}
+
+// Iterate returns an iterator over the chunks in the region selected by sel,
+// or all of them if sel is nil. Closing the iterator doesn't close the region.
+func (r *RegionReader) Iterate(sel Selector) *ChunkIterator {
+	it := &ChunkIterator{sel: sel}
+	it.setReader(r, nil, false)
+	return it
+}
+
+// Iterate returns an iterator over the chunks in the dimension selected by sel,
+// or all of them if sel is nil. Errors opening region files are reported as
+// errors for the chunk at the corner of the region.
+func (d *Dimension) Iterate(sel Selector) *ChunkIterator {
+	it := &ChunkIterator{sel: sel}
+	it.err = it.addDimension(d)
+	it.done = it.err != nil
+	return it
+}
+
+// Iterate returns an iterator over the chunks of every dimension in the world
+// selected by sel, or all of them if sel is nil, see Dimension.Iterate. Entity
+// and POI chunks are included after each dimension's terrain chunks, and are
+// told apart by their Kind.
+func (w *World) Iterate(sel Selector) *ChunkIterator {
+	it := &ChunkIterator{sel: sel}
+
+dimensions:
+	for _, dim := range w.Dimensions {
+		for _, view := range dim.Views() {
+			if it.err = it.addDimension(view); it.err != nil {
+				it.done = true
+				break dimensions
+			}
+		}
+	}
+
+	return it
+}
+
+func (it *ChunkIterator) addDimension(d *Dimension) error {
+	regions, err := d.Regions()
+	if err != nil {
+		return fmt.Errorf("anvil: failed to list regions of %s: %w", d.Name, err)
+	}
+
+	for _, region := range regions {
+		if it.sel == nil || it.sel.ContainsRegion(region) {
+			it.regions = append(it.regions, iterRegion{dimension: d, region: region})
+		}
+	}
+
+	return nil
+}
+
+func (it *ChunkIterator) setReader(rd *RegionReader, dimension *Dimension, close bool) {
+	it.rd = rd
+	it.closeRd = close
+	it.dimension = dimension
+
+	if it.sel == nil {
+		it.chunks = rd.Chunks()
+	} else {
+		it.chunks = rd.SelectChunks(it.sel)
+	}
+}
+
+// Next advances the iterator to the next chunk, returning false once there are
+// no more chunks or iteration failed. If reading the chunk failed, Next still
+// returns true and the error is returned by Err.
+func (it *ChunkIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	it.chunk = ChunkData{}
+	it.err = nil
+
+	for len(it.chunks) == 0 {
+		it.closeReader()
+
+		if len(it.regions) == 0 {
+			it.done = true
+			return false
+		}
+
+		next := it.regions[0]
+		it.regions = it.regions[1:]
+
+		rd, err := next.dimension.OpenRegion(next.region)
+		if err != nil {
+			it.chunk = ChunkData{
+				Chunk:     next.region.CornerChunk(),
+				Dimension: next.dimension.Name,
+				Kind:      next.dimension.Kind,
+			}
+			it.err = fmt.Errorf("anvil: failed to open region: %w", err)
+			return true
+		}
+
+		it.setReader(rd, next.dimension, true)
+	}
+
+	chunk := it.chunks[0]
+	it.chunks = it.chunks[1:]
+
+	it.chunk, it.err = it.rd.ReadChunk(chunk)
+	it.chunk.Chunk = chunk
+
+	if it.dimension != nil {
+		it.chunk.Dimension = it.dimension.Name
+		it.chunk.Kind = it.dimension.Kind
+	}
+
+	return true
+}
+
+// Chunk returns the current chunk.
+func (it *ChunkIterator) Chunk() ChunkData {
+	return it.chunk
+}
+
+// Err returns the error reading the current chunk, or once Next has returned
+// false, the error that stopped the iteration, if any.
+func (it *ChunkIterator) Err() error {
+	return it.err
+}
+
+// Close stops the iteration and closes the region file it has open. It is safe
+// to call Close at any time, and more than once.
+func (it *ChunkIterator) Close() error {
+	it.done = true
+	it.regions = nil
+	it.chunks = nil
+	return it.closeReader()
+}
+
+func (it *ChunkIterator) closeReader() error {
+	rd := it.rd
+	it.rd = nil
+
+	if rd == nil || !it.closeRd {
+		return nil
+	}
+
+	return rd.Close()
+}
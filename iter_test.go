@@ -0,0 +1,82 @@
+package anvil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkIterator(t *testing.T) {
+	dir := writeTestWorld(t, map[string][]testChunk{
+		"region/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("a"), 0},
+			{Chunk{X: 1, Z: 0}, CompressionUncompressed | externalFlag, nil, 0},
+			{Chunk{X: 2, Z: 0}, CompressionUncompressed, []byte("b"), 0},
+		},
+		"region/r.1.0.mca": nil,
+		"DIM-1/region/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("c"), 0},
+		},
+	}, "level.dat")
+	defer os.RemoveAll(dir)
+
+	if err := os.Truncate(filepath.Join(dir, "region", "r.1.0.mca"), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenWorld(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	it := w.Iterate(nil)
+
+	var data []string
+	var failed []Chunk
+
+	for it.Next() {
+		if it.Err() != nil {
+			failed = append(failed, it.Chunk().Chunk)
+			continue
+		}
+
+		data = append(data, it.Chunk().Dimension+" "+string(it.Chunk().Data))
+	}
+
+	assert.NoError(t, it.Err())
+	assert.NoError(t, it.Close())
+	assert.Equal(t, []string{
+		Overworld + " a",
+		Overworld + " b",
+		Nether + " c",
+	}, data)
+	// the missing external chunk and the truncated region
+	assert.Equal(t, []Chunk{{X: 1, Z: 0}, {X: 32, Z: 0}}, failed)
+
+	// stopping early closes the open region
+	it = w.Dimension(Overworld).Iterate(NewChunkListSelector([]Chunk{{X: 2, Z: 0}}))
+	assert.True(t, it.Next())
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []byte("b"), it.Chunk().Data)
+	assert.NoError(t, it.Close())
+	assert.False(t, it.Next())
+
+	rd, err := OpenRegionFile(filepath.Join(dir, "region", "r.0.0.mca"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rd.Close()
+
+	it = rd.Iterate(nil)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	assert.Equal(t, 3, count)
+
+	it = (&Dimension{RegionDir: filepath.Join(dir, "missing")}).Iterate(nil)
+	assert.False(t, it.Next())
+	assert.Error(t, it.Err())
+}
@@ -0,0 +1,256 @@
+package anvil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The .linear format stores a region as a single zstd compressed blob between
+// a 32 byte header and an 8 byte footer. The header consists of the superblock
+// magic, the version, the newest chunk timestamp, the zstd compression level,
+// the number of chunks, the length of the compressed blob and 8 reserved
+// bytes, and the footer is the superblock magic again. The decompressed blob
+// starts with the size and timestamp of each of the 1024 chunks, followed by
+// the uncompressed NBT data of the chunks in order.
+const (
+	linearSuperblock  = 0xc3ff13183cca9d9a
+	linearVersion     = 1
+	linearHeaderSize  = 32
+	linearFooterSize  = 8
+	linearTableSize   = 1024 * 8
+	linearCompression = 3 // zstd's default level
+)
+
+var (
+	ErrInvalidLinear = errors.New("anvil: invalid .linear region file")
+	ErrLinearRegion  = errors.New("anvil: .linear region files can only be read, convert them to .mca first")
+)
+
+// NewLinearRegionReader returns a region reader over the contents of a .linear
+// region file. The whole region is decompressed into memory, and its chunks
+// are returned uncompressed.
+func NewLinearRegionReader(data []byte, region Region) (*RegionReader, error) {
+	if len(data) < linearHeaderSize+linearFooterSize {
+		return nil, fmt.Errorf("%w: file is too small", ErrInvalidLinear)
+	}
+
+	header := data[:linearHeaderSize]
+	footer := data[len(data)-linearFooterSize:]
+
+	if binary.BigEndian.Uint64(header) != linearSuperblock ||
+		binary.BigEndian.Uint64(footer) != linearSuperblock {
+		return nil, fmt.Errorf("%w: bad superblock", ErrInvalidLinear)
+	}
+
+	if header[8] != linearVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidLinear, header[8])
+	}
+
+	compressedLen := int(binary.BigEndian.Uint32(header[20:24]))
+	if linearHeaderSize+compressedLen+linearFooterSize != len(data) {
+		return nil, fmt.Errorf("%w: compressed length %d doesn't match file size",
+			ErrInvalidLinear, compressedLen)
+	}
+
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	blob, err := dec.DecodeAll(data[linearHeaderSize:linearHeaderSize+compressedLen], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLinear, err)
+	}
+
+	if len(blob) < linearTableSize {
+		return nil, fmt.Errorf("%w: chunk table is truncated", ErrInvalidLinear)
+	}
+
+	r := &RegionReader{
+		Region: region,
+		Format: FormatLinear,
+		ReadExternal: func(chunk Chunk) ([]byte, error) {
+			return nil, ErrExternalUnavailable
+		},
+		header: make([]byte, headerSize),
+		size:   int64(len(data)),
+		linear: make([][]byte, 1024),
+	}
+
+	pos := linearTableSize
+
+	for i := range r.linear {
+		size := int(binary.BigEndian.Uint32(blob[i*8:]))
+		if size == 0 {
+			continue
+		}
+
+		if pos+size > len(blob) {
+			return nil, fmt.Errorf("%w: chunk %v extends past end of data",
+				ErrInvalidLinear, region.OffsetToChunk(i*4))
+		}
+
+		r.linear[i] = blob[pos : pos+size : pos+size]
+		pos += size
+
+		// the location table only has to mark the chunk as present
+		r.header[i*4+2] = 1
+		copy(r.header[timestampOffset+i*4:], blob[i*8+4:i*8+8])
+	}
+
+	return r, nil
+}
+
+// openLinearRegionFile is OpenRegionFile for .linear files.
+func openLinearRegionFile(filename string, region Region) (*RegionReader, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLinearRegionReader(data, region)
+}
+
+// WriteLinearRegion writes all of the chunks in the region to wr in the
+// .linear format, decompressing them if necessary.
+func WriteLinearRegion(wr io.Writer, rd *RegionReader) error {
+	blob := make([]byte, linearTableSize)
+	var newest uint32
+	var count int
+
+	for i := 0; i < 4096; i += 4 {
+		if rd.sectorOffset(i) == 0 {
+			continue
+		}
+
+		c, err := rd.readChunkData(i)
+		if err != nil {
+			return err
+		}
+
+		data, err := c.Decompress()
+		if err != nil {
+			return err
+		}
+
+		var timestamp uint32
+		if !c.LastModified.IsZero() {
+			timestamp = uint32(c.LastModified.Unix())
+		}
+
+		if timestamp > newest {
+			newest = timestamp
+		}
+
+		entry := blob[i*2 : i*2+8]
+		binary.BigEndian.PutUint32(entry, uint32(len(data)))
+		binary.BigEndian.PutUint32(entry[4:], timestamp)
+
+		blob = append(blob, data...)
+		count++
+	}
+
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	if err != nil {
+		return err
+	}
+	compressed := enc.EncodeAll(blob, nil)
+	enc.Close()
+
+	header := make([]byte, linearHeaderSize)
+	binary.BigEndian.PutUint64(header, linearSuperblock)
+	header[8] = linearVersion
+	binary.BigEndian.PutUint64(header[9:], uint64(newest))
+	header[17] = linearCompression
+	binary.BigEndian.PutUint16(header[18:], uint16(count))
+	binary.BigEndian.PutUint32(header[20:], uint32(len(compressed)))
+
+	footer := make([]byte, linearFooterSize)
+	binary.BigEndian.PutUint64(footer, linearSuperblock)
+
+	for _, data := range [][]byte{header, compressed, footer} {
+		if _, err := wr.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConvertToLinear converts the region file at src to a .linear region file at
+// dst, replacing it if it exists. Like OpenRegionWriterAtomic, ErrWorldLocked
+// is returned if dst's world is in use by a running server, unless opts.Force
+// is set.
+func ConvertToLinear(src, dst string, opts AtomicOptions) error {
+	if err := checkWorldLock(dst, opts); err != nil {
+		return err
+	}
+
+	rd, err := OpenRegionFile(src)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	buf := new(bytes.Buffer)
+	if err := WriteLinearRegion(buf, rd); err != nil {
+		return err
+	}
+
+	if opts.Backup {
+		if err := backupFile(dst); err != nil {
+			return fmt.Errorf("anvil: failed to back up region: %w", err)
+		}
+	}
+
+	return writeFileAtomic(dst, buf.Bytes())
+}
+
+// ConvertFromLinear converts the .linear region file at src to a region file
+// at dst, compressing chunks with zlib. dst is replaced if it exists, and
+// like OpenRegionWriterAtomic, ErrWorldLocked is returned if dst's world is
+// in use by a running server, unless opts.Force is set.
+func ConvertFromLinear(src, dst string, opts AtomicOptions) error {
+	rd, err := OpenRegionFile(src)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	w, err := openRegionWriterAtomic(dst, opts, false)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	for _, chunk := range rd.Chunks() {
+		c, err := rd.ReadChunk(chunk)
+		if err != nil {
+			return err
+		}
+
+		data, err := c.Decompress()
+		if err != nil {
+			return err
+		}
+
+		c.Data, err = CompressionZlib.Compress(data)
+		if err != nil {
+			return err
+		}
+		c.Compression = CompressionZlib
+
+		if err := w.WriteRawChunk(c); err != nil {
+			return err
+		}
+	}
+
+	return w.Commit()
+}
@@ -0,0 +1,94 @@
+package anvil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinear(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	regionDir := filepath.Join(dir, "region")
+	if err := os.Mkdir(regionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	mca := filepath.Join(dir, "r.1.-1.mca")
+	writeTestRegion(t, mca, []testChunk{
+		{Chunk{X: 32, Z: -32}, CompressionZlib, zlibCompress([]byte("first")), 1600000000},
+		{Chunk{X: 63, Z: -1}, CompressionUncompressed, []byte("second"), 1500000000},
+	})
+
+	linear := filepath.Join(regionDir, "r.1.-1.linear")
+	if !assert.NoError(t, ConvertToLinear(mca, linear, AtomicOptions{})) {
+		return
+	}
+
+	rd, err := OpenRegionFile(linear)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, FormatLinear, rd.Format)
+	assert.Equal(t, []Chunk{{X: 32, Z: -32}, {X: 63, Z: -1}}, rd.Chunks())
+
+	c, err := rd.ReadChunk(Chunk{X: 32, Z: -32})
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionUncompressed, c.Compression)
+	assert.Equal(t, FormatLinear, c.Format)
+	assert.Equal(t, []byte("first"), c.Data)
+	assert.Equal(t, time.Unix(1600000000, 0), c.LastModified)
+
+	_, err = rd.ReadChunk(Chunk{X: 33, Z: -32})
+	assert.True(t, errors.Is(err, ErrChunkNotPresent))
+	assert.NoError(t, rd.Close())
+
+	_, err = OpenRegionWriter(linear)
+	assert.Equal(t, ErrLinearRegion, err)
+
+	w, err := OpenWorld(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	overworld := w.Dimension(Overworld)
+	assert.Equal(t, FormatLinear, overworld.Format)
+	c, err = overworld.ReadChunk(Chunk{X: 63, Z: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), c.Data)
+
+	back := filepath.Join(dir, "back", "r.1.-1.mca")
+	if err := os.Mkdir(filepath.Dir(back), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if !assert.NoError(t, ConvertFromLinear(linear, back, AtomicOptions{})) {
+		return
+	}
+
+	rd, err = OpenRegionFile(back)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rd.Close()
+
+	c, err = rd.ReadChunk(Chunk{X: 63, Z: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionZlib, c.Compression)
+	assert.Equal(t, time.Unix(1500000000, 0), c.LastModified)
+	data, err := c.Decompress()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	_, err = NewLinearRegionReader([]byte("not a linear region file at all..."), Region{})
+	assert.True(t, errors.Is(err, ErrInvalidLinear))
+}
@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package anvil
+
+import "os"
+
+// sessionLockHeld returns ErrLockUnsupported, as there's no way to check the
+// lock on this platform.
+func sessionLockHeld(f *os.File) (bool, error) {
+	return false, ErrLockUnsupported
+}
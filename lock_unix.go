@@ -0,0 +1,28 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package anvil
+
+import (
+	"os"
+	"syscall"
+)
+
+// sessionLockHeld returns whether another process holds a lock on the file,
+// which is how servers since 1.16 lock session.lock. Java takes the lock with
+// fcntl on every unix platform, so it can be checked the same way on all of
+// them.
+func sessionLockHeld(f *os.File) (bool, error) {
+	lock := syscall.Flock_t{
+		Type:   syscall.F_WRLCK,
+		Whence: 0,
+		Start:  0,
+		Len:    0,
+	}
+
+	if err := syscall.FcntlFlock(f.Fd(), syscall.F_GETLK, &lock); err != nil {
+		return false, err
+	}
+
+	return lock.Type != syscall.F_UNLCK, nil
+}
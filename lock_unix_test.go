@@ -0,0 +1,161 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package anvil
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHelperSessionLock isn't a real test, it's run by lockWorld in a child
+// process to hold the world's session.lock until its stdin is closed.
+func TestHelperSessionLock(t *testing.T) {
+	worldDir := os.Getenv("ANVIL_TEST_LOCK_WORLD")
+	if worldDir == "" {
+		return
+	}
+
+	f, err := os.OpenFile(filepath.Join(worldDir, SessionLockFilename), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK}
+	if err := syscall.FcntlFlock(f.Fd(), syscall.F_SETLKW, &lock); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Stdout.WriteString("locked\n")
+	ioutil.ReadAll(os.Stdin)
+	os.Exit(0)
+}
+
+// lockWorld locks the world's session.lock from another process like a
+// running server would, since a process can't see its own locks. The
+// returned function releases the lock.
+func lockWorld(t *testing.T, worldDir string) func() {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperSessionLock$")
+	cmd.Env = append(os.Environ(), "ANVIL_TEST_LOCK_WORLD="+worldDir)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); err != nil || line != "locked\n" {
+		stdin.Close()
+		cmd.Wait()
+		t.Fatalf("failed to lock session.lock: %q, %v", line, err)
+	}
+
+	return func() {
+		stdin.Close()
+		cmd.Wait()
+	}
+}
+
+func TestLinearLockedWorld(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	regionDir := filepath.Join(dir, "region")
+	if err := os.Mkdir(regionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	mca := filepath.Join(dir, "r.0.0.mca")
+	writeTestRegion(t, mca, []testChunk{
+		{Chunk{X: 1, Z: 2}, CompressionUncompressed, []byte("chunk"), 1600000000},
+	})
+
+	linear := filepath.Join(dir, "r.0.0.linear")
+	if !assert.NoError(t, ConvertToLinear(mca, linear, AtomicOptions{})) {
+		return
+	}
+
+	unlock := lockWorld(t, dir)
+	defer unlock()
+
+	dstLinear := filepath.Join(regionDir, "r.0.0.linear")
+	dstMCA := filepath.Join(regionDir, "r.0.0.mca")
+
+	assert.Equal(t, ErrWorldLocked, ConvertToLinear(mca, dstLinear, AtomicOptions{}))
+	assert.Equal(t, ErrWorldLocked, ConvertFromLinear(linear, dstMCA, AtomicOptions{}))
+
+	for _, filename := range []string{dstLinear, dstMCA} {
+		_, err := os.Stat(filename)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	assert.NoError(t, ConvertToLinear(mca, dstLinear, AtomicOptions{Force: true}))
+	assert.NoError(t, ConvertFromLinear(linear, dstMCA, AtomicOptions{Force: true}))
+}
+
+func TestRegionWriterAtomicLocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	regionDir := filepath.Join(dir, "region")
+	if err := os.Mkdir(regionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(regionDir, "r.0.0.mca")
+	writeTestRegion(t, filename, []testChunk{
+		{Chunk{X: 1, Z: 2}, CompressionUncompressed, []byte("chunk"), 1600000000},
+	})
+
+	unlock := lockWorld(t, dir)
+
+	locked, err := WorldLocked(dir)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	_, err = OpenRegionWriterAtomic(filename, AtomicOptions{})
+	assert.Equal(t, ErrWorldLocked, err)
+
+	// the world is found from the region's directory unless it's given
+	w, err := OpenRegionWriterAtomic(filename, AtomicOptions{WorldDir: regionDir})
+	if assert.NoError(t, err) {
+		assert.NoError(t, w.Close())
+	}
+
+	w, err = OpenRegionWriterAtomic(filename, AtomicOptions{Force: true})
+	if assert.NoError(t, err) {
+		assert.NoError(t, w.Close())
+	}
+
+	unlock()
+
+	locked, err = WorldLocked(dir)
+	assert.NoError(t, err)
+	assert.False(t, locked)
+
+	w, err = OpenRegionWriterAtomic(filename, AtomicOptions{})
+	if assert.NoError(t, err) {
+		assert.NoError(t, w.Close())
+	}
+}
@@ -0,0 +1,17 @@
+package anvil
+
+import (
+	"io"
+	"os"
+)
+
+// sessionLockHeld returns whether another process holds a lock on the file.
+// On Windows the lock taken by the server prevents reading the file.
+func sessionLockHeld(f *os.File) (bool, error) {
+	var buf [1]byte
+	if _, err := f.Read(buf[:]); err != nil && err != io.EOF {
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,274 @@
+package nbt
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/tinylib/msgp/msgp"
+	"github.com/tmpim/anvil"
+)
+
+// indexVersion is the version of the encoding used by EncodeIndex, which
+// must be incremented if the index's layout changes.
+const indexVersion = 1
+
+// indexEntryFields is the number of fields of an encoded IndexEntry.
+const indexEntryFields = 7
+
+var (
+	ErrInvalidIndex   = errors.New("nbt: invalid encoded index")
+	ErrIndexNotCached = errors.New("nbt: index prepared but not cached")
+)
+
+// EncodeIndex encodes the reader's index as msgpack, to be restored with
+// DecodeIndex on a reader over the same data. The encoding is an array of the
+// encoding version, the length of the data and the entries, each of which is
+// an array of its fields in order.
+func (r *Reader) EncodeIndex() ([]byte, error) {
+	if r.Index == nil {
+		return nil, ErrNotIndexed
+	}
+
+	size := msgp.ArrayHeaderSize + 2*msgp.Uint64Size + msgp.ArrayHeaderSize +
+		len(r.Index)*(msgp.ArrayHeaderSize+3*msgp.Uint64Size+4*msgp.Int32Size)
+	b := make([]byte, 0, size)
+
+	b = msgp.AppendArrayHeader(b, 3)
+	b = msgp.AppendUint(b, indexVersion)
+	b = msgp.AppendInt(b, len(r.data))
+	b = msgp.AppendArrayHeader(b, uint32(len(r.Index)))
+
+	for _, ent := range r.Index {
+		b = msgp.AppendArrayHeader(b, indexEntryFields)
+		b = msgp.AppendUint64(b, uint64(ent.Name))
+		b = msgp.AppendUint64(b, uint64(ent.Data))
+		b = msgp.AppendInt32(b, ent.Parent)
+		b = msgp.AppendInt32(b, ent.FirstChild)
+		b = msgp.AppendInt32(b, ent.NextSibling)
+		b = msgp.AppendInt32(b, ent.ListIndex)
+		b = msgp.AppendUint64(b, uint64(ent.Flags))
+	}
+
+	return b, nil
+}
+
+// DecodeIndex restores the reader's index from an index encoded by
+// EncodeIndex, replacing any existing index. The index is checked to be
+// consistent with the reader's data, but it isn't reindexed, so it must have
+// been encoded from a reader over the same data.
+func (r *Reader) DecodeIndex(b []byte) error {
+	index, err := r.decodeIndex(b)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIndex, err)
+	}
+
+	r.Index = index
+	return nil
+}
+
+func (r *Reader) decodeIndex(b []byte) (Index, error) {
+	sz, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	} else if sz != 3 {
+		return nil, fmt.Errorf("expected 3 fields, got %d", sz)
+	}
+
+	version, b, err := msgp.ReadUintBytes(b)
+	if err != nil {
+		return nil, err
+	} else if version != indexVersion {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	length, b, err := msgp.ReadIntBytes(b)
+	if err != nil {
+		return nil, err
+	} else if length != len(r.data) {
+		return nil, fmt.Errorf("index is for %d bytes of data, reader has %d",
+			length, len(r.data))
+	}
+
+	count, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// each entry is at least one byte per field
+	if int(count) > len(b)/indexEntryFields {
+		return nil, fmt.Errorf("%d entries don't fit in %d bytes", count, len(b))
+	}
+
+	index := make(Index, count)
+
+	for i := range index {
+		ent := &index[i]
+
+		sz, b, err = msgp.ReadArrayHeaderBytes(b)
+		if err != nil {
+			return nil, err
+		} else if sz != indexEntryFields {
+			return nil, fmt.Errorf("entry %d: expected %d fields, got %d", i,
+				indexEntryFields, sz)
+		}
+
+		var name, data, flags uint64
+
+		if name, b, err = msgp.ReadUint64Bytes(b); err != nil {
+			return nil, err
+		}
+		if data, b, err = msgp.ReadUint64Bytes(b); err != nil {
+			return nil, err
+		}
+		if ent.Parent, b, err = msgp.ReadInt32Bytes(b); err != nil {
+			return nil, err
+		}
+		if ent.FirstChild, b, err = msgp.ReadInt32Bytes(b); err != nil {
+			return nil, err
+		}
+		if ent.NextSibling, b, err = msgp.ReadInt32Bytes(b); err != nil {
+			return nil, err
+		}
+		if ent.ListIndex, b, err = msgp.ReadInt32Bytes(b); err != nil {
+			return nil, err
+		}
+		if flags, b, err = msgp.ReadUint64Bytes(b); err != nil {
+			return nil, err
+		}
+
+		ent.Name, ent.Data, ent.Flags = Slice(name), Slice(data), Flags(flags)
+	}
+
+	if len(b) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes", len(b))
+	}
+
+	return index, index.check(len(r.data))
+}
+
+// check checks that the index's entries are within data of the given length,
+// sorted, and only link to entries in the index, so that using it can't
+// panic.
+func (idx Index) check(length int) error {
+	for i := range idx {
+		ent := &idx[i]
+
+		for _, s := range []Slice{ent.Name, ent.Data} {
+			if s.Pos()+s.Len() > length {
+				return fmt.Errorf("entry %d: extends past end of data", i)
+			}
+		}
+
+		if i > 0 && ent.Pos() <= idx[i-1].Pos() {
+			return fmt.Errorf("entry %d: not sorted by position", i)
+		}
+
+		for _, link := range []int32{ent.Parent, ent.FirstChild, ent.NextSibling} {
+			if link < -1 || int(link) >= len(idx) {
+				return fmt.Errorf("entry %d: links to entry %d, index has %d", i,
+					link, len(idx))
+			}
+		}
+	}
+
+	return nil
+}
+
+// IndexCache stores the indexes of chunks in msgpack files in Dir, so that
+// repeated queries over the same world don't need to index chunks whose data
+// hasn't changed. Indexes are keyed by the hash of the chunk's data and the
+// tags selected, so one cache can be shared by queries selecting different
+// tags.
+type IndexCache struct {
+	Dir string
+}
+
+// PrepareIndex prepares the index of a reader over the chunk's decompressed
+// data like Reader.PrepareIndex, loading it from the cache if it has been
+// cached before, and caching it otherwise. If the index is prepared but can't
+// be cached, such as when the cache directory isn't accessible, an error
+// wrapping ErrIndexNotCached is returned, which can be ignored as the reader's
+// index is still usable.
+func (c *IndexCache) PrepareIndex(r *Reader, chunk *anvil.ChunkData, sel SelectiveIndex) error {
+	if r.Index != nil {
+		return nil
+	}
+
+	filename := c.filename(chunk, sel)
+
+	data, err := ioutil.ReadFile(filename)
+	if err == nil && r.DecodeIndex(data) == nil {
+		return nil
+	}
+
+	// the index is missing, unreadable or invalid, so replace it
+	if err := r.PrepareIndex(sel); err != nil {
+		return err
+	}
+
+	data, err = r.EncodeIndex()
+	if err == nil {
+		err = writeCacheFile(filename, data)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIndexNotCached, err)
+	}
+
+	return nil
+}
+
+// filename returns the path to the cached index of the chunk's data with the
+// given selection.
+func (c *IndexCache) filename(chunk *anvil.ChunkData, sel SelectiveIndex) string {
+	hash := chunk.Hash()
+	key := hex.EncodeToString(hash[:])
+
+	selKey := "all"
+	if sel != nil {
+		h := fnv.New64a()
+		for _, header := range sel {
+			h.Write(header.Bytes())
+		}
+		selKey = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return filepath.Join(c.Dir, key[:2], key+"-"+selKey+".idx")
+}
+
+// writeCacheFile writes the file by renaming a temporary file into place, so
+// concurrent readers never see a partially written index.
+func writeCacheFile(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, ".idx-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), filename); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package nbt
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmpim/anvil"
+)
+
+func TestEncodeIndex(t *testing.T) {
+	data := testChunkNBT(5)
+
+	rd := NewReader(data)
+	_, err := rd.EncodeIndex()
+	assert.Equal(t, ErrNotIndexed, err)
+
+	assert.NoError(t, rd.PrepareIndex(nil))
+	encoded, err := rd.EncodeIndex()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	decoded := NewReader(data)
+	assert.NoError(t, decoded.DecodeIndex(encoded))
+	assert.Equal(t, rd.Index, decoded.Index)
+
+	// the index must be for the same data
+	other := NewReader(append(testChunkNBT(5), 0))
+	assert.True(t, errors.Is(other.DecodeIndex(encoded), ErrInvalidIndex))
+	assert.Nil(t, other.Index)
+
+	for _, n := range []int{0, 1, len(encoded) / 2, len(encoded) - 1} {
+		assert.True(t, errors.Is(decoded.DecodeIndex(encoded[:n]), ErrInvalidIndex))
+	}
+
+	// links outside of the index
+	rd.Index[3].Parent = int32(len(rd.Index))
+	corrupt, _ := rd.EncodeIndex()
+	assert.True(t, errors.Is(decoded.DecodeIndex(corrupt), ErrInvalidIndex))
+}
+
+func TestIndexCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache := &IndexCache{Dir: dir}
+	chunk := &anvil.ChunkData{
+		Compression: anvil.CompressionUncompressed,
+		Data:        testChunkNBT(5),
+	}
+	sel := SelectiveIndex{{TagID: TagList, Name: []byte("TileEntities")}}
+
+	rd := NewReader(chunk.Data)
+	assert.NoError(t, cache.PrepareIndex(&rd, chunk, sel))
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*.idx"))
+	assert.Len(t, files, 1)
+
+	cached := NewReader(chunk.Data)
+	assert.NoError(t, cache.PrepareIndex(&cached, chunk, sel))
+	assert.Equal(t, rd.Index, cached.Index)
+
+	// a different selection is cached separately
+	all := NewReader(chunk.Data)
+	assert.NoError(t, cache.PrepareIndex(&all, chunk, nil))
+	assert.True(t, len(all.Index) > len(rd.Index))
+
+	files, _ = filepath.Glob(filepath.Join(dir, "*", "*.idx"))
+	assert.Len(t, files, 2)
+
+	// corrupt cache files are replaced
+	for _, file := range files {
+		assert.NoError(t, ioutil.WriteFile(file, []byte("corrupt"), 0644))
+	}
+
+	reindexed := NewReader(chunk.Data)
+	assert.NoError(t, cache.PrepareIndex(&reindexed, chunk, sel))
+	assert.Equal(t, rd.Index, reindexed.Index)
+
+	cached = NewReader(chunk.Data)
+	assert.NoError(t, cache.PrepareIndex(&cached, chunk, sel))
+	assert.Equal(t, rd.Index, cached.Index)
+
+	// failing to write the cache still prepares the index
+	unwritable := &IndexCache{Dir: files[0]}
+	uncached := NewReader(chunk.Data)
+	err = unwritable.PrepareIndex(&uncached, chunk, sel)
+	assert.True(t, errors.Is(err, ErrIndexNotCached))
+	assert.Equal(t, rd.Index, uncached.Index)
+}
@@ -2,141 +2,138 @@ package nbt
 
 import (
 	"bytes"
-	"compress/zlib"
+	"encoding/json"
 	"io"
 
-	"github.com/ppacher/nbt"
 	"github.com/tmpim/anvil"
 )
 
-// func (r *Reader) StructureToJSON(entry *IndexEntry) []byte {
-// 	results := map[string]interface{}{
-// 		string(entry.Header.Name): r.recursiveIface(entry),
-// 	}
-
-// 	data, err := json.Marshal(results)
-// 	if err != nil {
-// 		panic(err)
-// 	}
-
-// 	return data
-// }
-
-// func (r *Reader) recursiveIface(entry *IndexEntry) interface{} {
-// 	switch entry.Header.TagID {
-// 	case TagList:
-// 		results := make([]interface{}, len(entry.Children))
-// 		for i, child := range entry.Children {
-// 			results[i] = r.recursiveIface(child)
-// 		}
-// 		return results
-// 	case TagCompound:
-// 		results := make(map[string]interface{})
-// 		for _, child := range entry.Children {
-// 			results[string(child.Header.Name)] = r.recursiveIface(child)
-// 		}
-// 		return results
-// 	default:
-// 		return entry.Header.TagID
-// 	}
-// }
-
-// type TileEntityDetails struct {
-// 	Location  anvil.Coord
-// 	Container bool
-// 	Count     int
-// }
-
-// func (r *Reader) GetTileEntityDetails(ent *IndexEntry) *TileEntityDetails {
-// 	xStr := []byte("x")
-// 	yStr := []byte("y")
-// 	zStr := []byte("z")
-// 	countStr := []byte("Count")
-
-// 	var foundCoord anvil.Coord
-
-// 	var count byte
-// 	foundLocation := false
-// 	cur := ent.Parent
-
-// 	for cur != nil {
-// 		var x, y, z int
-// 		found := 0
-
-// 		for _, child := range cur.Children {
-// 			name := child.Header.Name
-// 			if bytes.Equal(name, xStr) && !foundLocation {
-// 				r.SeekTo(child.Pos)
-// 				_, err := r.ReadImmediate(nbt.TagInt, &x)
-// 				if err != nil {
-// 					panic(err)
-// 				}
-// 				found++
-// 			} else if bytes.Equal(name, yStr) && !foundLocation {
-// 				r.SeekTo(child.Pos)
-// 				_, err := r.ReadImmediate(nbt.TagInt, &y)
-// 				if err != nil {
-// 					panic(err)
-// 				}
-// 				found++
-// 			} else if bytes.Equal(name, zStr) && !foundLocation {
-// 				r.SeekTo(child.Pos)
-// 				_, err := r.ReadImmediate(nbt.TagInt, &z)
-// 				if err != nil {
-// 					panic(err)
-// 				}
-// 				found++
-// 			} else if bytes.Equal(name, countStr) && count == 0 {
-// 				r.SeekTo(child.Pos)
-// 				_, err := r.ReadImmediate(nbt.TagByte, &count)
-// 				if err != nil {
-// 					panic(err)
-// 				}
-// 			}
-// 		}
-
-// 		if found == 3 {
-// 			foundLocation = true
-// 			foundCoord = anvil.Coord{x, y, z}
-
-// 			if cur == ent.Parent {
-// 				return &TileEntityDetails{
-// 					Location:  foundCoord,
-// 					Container: false,
-// 					Count:     1,
-// 				}
-// 			}
-// 		}
-
-// 		cur = cur.Parent
-// 	}
-
-// 	if foundLocation && count == 0 {
-// 		return &TileEntityDetails{
-// 			Location:  foundCoord,
-// 			Container: true,
-// 			Count:     1,
-// 		}
-// 	} else if foundLocation {
-// 		return &TileEntityDetails{
-// 			Location:  foundCoord,
-// 			Container: true,
-// 			Count:     int(count),
-// 		}
-// 	}
-
-// 	return nil
-// }
+// StructureToJSON returns the structure of the indexed tags at and inside the
+// entry at position i of the index as JSON, with the tag IDs of the tags which
+// aren't lists or compounds as values.
+func (r *Reader) StructureToJSON(i int) []byte {
+	name := "root"
+	if ent := &r.Index[i]; ent.Flags&FlagIsTag != 0 {
+		name = string(r.EntryName(ent))
+	}
+
+	results := map[string]interface{}{
+		name: r.recursiveIface(i),
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func (r *Reader) recursiveIface(i int) interface{} {
+	children := r.Index.Children(i)
+
+	switch r.Index[i].TagID() {
+	case TagList:
+		results := make([]interface{}, len(children))
+		for j, child := range children {
+			results[j] = r.recursiveIface(child)
+		}
+		return results
+	case TagCompound:
+		results := make(map[string]interface{})
+		for _, child := range children {
+			results[string(r.EntryName(&r.Index[child]))] = r.recursiveIface(child)
+		}
+		return results
+	default:
+		return r.Index[i].TagID()
+	}
+}
+
+type TileEntityDetails struct {
+	Location  anvil.Coord
+	Container bool
+	Count     int
+}
+
+// GetTileEntityDetails returns the location of the tile entity containing
+// the entry, and whether it's inside a container and the count of its item
+// stack if so. It returns nil if no ancestor of the entry has coordinates.
+func (r *Reader) GetTileEntityDetails(ent *IndexEntry) *TileEntityDetails {
+	var foundCoord anvil.Coord
+
+	var count byte
+	foundLocation := false
+	parent := ent.Parent
+
+	for cur := parent; cur >= 0; cur = r.Index[cur].Parent {
+		flags := r.Index[cur].Flags
+
+		if flags&FlagHasCount != 0 && count == 0 {
+			r.readChild(int(cur), "Count", TagByte, &count)
+		}
+
+		if flags&FlagHasCoords == 0 || foundLocation {
+			continue
+		}
+
+		foundLocation = true
+		r.readChild(int(cur), "x", TagInt, &foundCoord.X)
+		r.readChild(int(cur), "y", TagInt, &foundCoord.Y)
+		r.readChild(int(cur), "z", TagInt, &foundCoord.Z)
+
+		if cur == parent {
+			return &TileEntityDetails{
+				Location:  foundCoord,
+				Container: false,
+				Count:     1,
+			}
+		}
+	}
+
+	if foundLocation && count == 0 {
+		return &TileEntityDetails{
+			Location:  foundCoord,
+			Container: true,
+			Count:     1,
+		}
+	} else if foundLocation {
+		return &TileEntityDetails{
+			Location:  foundCoord,
+			Container: true,
+			Count:     int(count),
+		}
+	}
+
+	return nil
+}
+
+// readChild reads the child of the entry at position i of the index with the
+// given name and tag ID into value.
+func (r *Reader) readChild(i int, name string, tagID TagID, value interface{}) {
+	for c := r.Index[i].FirstChild; c >= 0; c = r.Index[c].NextSibling {
+		child := &r.Index[c]
+		if child.TagID() != tagID || string(r.EntryName(child)) != name {
+			continue
+		}
+
+		r.SeekTo(child.Pos())
+		if _, err := r.ReadImmediate(tagID, value); err != nil {
+			panic(err)
+		}
+		return
+	}
+}
 
 func NewTileEntitiesReader(data *anvil.ChunkData) (Reader, error) {
-	rd, err := zlib.NewReader(bytes.NewReader(data.Data))
+	rd, err := data.NewReader()
 	if err != nil {
 		return Reader{}, err
 	}
 	defer rd.Close()
 
 	target := (&TagHeader{
-		TagID: nbt.TagList,
+		TagID: TagList,
 		Name:  []byte("TileEntities"),
 	}).Bytes()
 
@@ -163,7 +160,7 @@ func NewTileEntitiesReader(data *anvil.ChunkData) (Reader, error) {
 	bbuf := bytes.NewBuffer(buf)
 
 	endTarget := (&TagHeader{
-		TagID: nbt.TagList,
+		TagID: TagList,
 		Name:  []byte("Entities"),
 	}).Bytes()
 
@@ -1,26 +1,32 @@
 package nbt
 
-//go:generate msgp
-
 import (
 	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 
-	"github.com/tinylib/msgp/msgp"
 	"github.com/tmpim/anvil"
 )
 
-type IndexWrapper []FlatIndexEntry
-
+// Slice is the position and length of a range of the reader's data, packed
+// into a single integer with the length in the high 32 bits.
 type Slice uint64
 
+// Flags are the flags of an index entry, with the entry's tag ID in the top
+// byte.
 type Flags uint64
 
 const (
+	// FlagIsTag is set for entries of tags in the data, it's unset for the
+	// synthetic root added by PrepareIndex.
 	FlagIsTag = Flags(1 << iota)
+	// FlagHasCoords is set for compounds with x, y and z int tags, such as
+	// tile entities.
 	FlagHasCoords
+	// FlagHasCount is set for compounds with a Count byte tag, such as item
+	// stacks.
 	FlagHasCount
 )
 
@@ -30,6 +36,16 @@ func (s Slice) Apply(data []byte) []byte {
 	return data[pos : pos+length]
 }
 
+// Pos returns the position of the start of the slice.
+func (s Slice) Pos() int {
+	return int(s & 0xffffffff)
+}
+
+// Len returns the length of the slice.
+func (s Slice) Len() int {
+	return int(s >> 32)
+}
+
 func NewSlice(pos, length int) Slice {
 	return Slice(length<<32 | pos&0xffffffff)
 }
@@ -40,60 +56,115 @@ type Origin struct {
 	Player    string
 }
 
+// Index is the index of a reader's tags, sorted by their position. Entries
+// refer to each other by their position in the index, so an index contains
+// no pointers and is cheap for the garbage collector even for large scans.
+type Index []IndexEntry
+
+// IndexEntry is an indexed tag. Name is the tag's name and Data its payload,
+// just after its header. The synthetic root added by PrepareIndex has neither.
 type IndexEntry struct {
-	Name     Slice
-	Data     Slice
-	Parent   *IndexEntry
-	Children []*IndexEntry
-	Flags    Flags
+	Name Slice
+	Data Slice
+
+	// Parent, FirstChild and NextSibling are the positions of the related
+	// entries in the index, or -1 if there is no such entry. With a selective
+	// index, Parent is the tag's nearest indexed ancestor.
+	Parent      int32
+	FirstChild  int32
+	NextSibling int32
+
+	// ListIndex is the tag's index in its parent list, or -1 if its parent
+	// is a compound.
+	ListIndex int32
+
+	Flags Flags
+}
+
+// Pos returns the position of the tag's payload.
+func (e *IndexEntry) Pos() int {
+	return e.Data.Pos()
 }
 
-func (f Flags) TagID() nbt.TagID {
-	return nbt.TagID(f >> (64 - 8))
+// TagID returns the tag's ID.
+func (e *IndexEntry) TagID() TagID {
+	return e.Flags.TagID()
 }
 
-func (f Flags) SetTagID(tagID nbt.TagID) Flags {
+func (f Flags) TagID() TagID {
+	return TagID(f >> (64 - 8))
+}
+
+func (f Flags) SetTagID(tagID TagID) Flags {
 	f |= FlagIsTag
 	f |= Flags(tagID) << (64 - 8)
 	return f
 }
 
-func toPos(e *IndexEntry) int {
-	if e == nil {
-		return -1
-	}
-	return e.Pos
+// Find returns the position in the index of the entry whose payload is at
+// the given position in the data.
+func (idx Index) Find(pos int) (int, bool) {
+	i := idx.Floor(pos)
+	return i, i >= 0 && idx[i].Pos() == pos
 }
 
-func toList(entries []*IndexEntry) []int {
-	results := make([]int, len(entries))
-	for i, v := range entries {
-		results[i] = v.Pos
+// Floor returns the position in the index of the last entry whose payload
+// is at or before the given position in the data, or -1 if there is none.
+func (idx Index) Floor(pos int) int {
+	return sort.Search(len(idx), func(i int) bool {
+		return idx[i].Pos() > pos
+	}) - 1
+}
+
+// Children returns the positions in the index of the entry's children.
+func (idx Index) Children(i int) []int {
+	var results []int
+	for c := idx[i].FirstChild; c >= 0; c = idx[c].NextSibling {
+		results = append(results, int(c))
 	}
 	return results
 }
 
-// EncodeIndex encodes the index.
-func (r *Reader) EncodeIndex() []byte {
-	var flat IndexWrapper
-
-	for _, v := range r.Index {
-		flat = append(flat, FlatIndexEntry{
-			P: v.Pos,
-			A: toPos(v.Parent),
-			C: toList(v.Children),
-			H: &v.Header,
-			I: v.ListIndex,
-		})
+// EntryName returns the name of the entry's tag.
+func (r *Reader) EntryName(e *IndexEntry) []byte {
+	return e.Name.Apply(r.data)
+}
+
+// EntryHeader returns the header of the entry's tag.
+func (r *Reader) EntryHeader(e *IndexEntry) TagHeader {
+	return TagHeader{
+		TagID: e.TagID(),
+		Name:  r.EntryName(e),
 	}
+}
 
-	buf := new(bytes.Buffer)
-	err := msgp.Encode(buf, flat)
-	if err != nil {
-		panic(err)
+// SelectiveIndex is the set of tags to index with PrepareIndex. A tag matches
+// if it has the same tag ID and name as one of the headers, and every tag
+// inside a matching tag is indexed too. A name containing slashes, such as
+// "Level/TileEntities", is matched against the path of compound names from
+// the root instead of just the tag's name. The root compound's name isn't
+// part of the path.
+type SelectiveIndex []TagHeader
+
+// Matches returns whether the tag with the given header at the given path
+// is selected. path is the slash separated names of the tag's parents
+// followed by its own name.
+func (s SelectiveIndex) Matches(header TagHeader, path []byte) bool {
+	for _, sel := range s {
+		if sel.TagID != header.TagID {
+			continue
+		}
+
+		if bytes.IndexByte(sel.Name, '/') >= 0 {
+			if bytes.Equal(sel.Name, path) {
+				return true
+			}
+		} else if bytes.Equal(sel.Name, header.Name) {
+			return true
+		}
 	}
 
-	return buf.Bytes()
+	return false
 }
 
 func (r *Reader) FastPrepareIndex() (err error) {
@@ -107,8 +178,6 @@ func (r *Reader) FastPrepareIndex() (err error) {
 		}
 	}()
 
-	r.Index = make(map[int]*IndexEntry)
-
 	savedCursor := r.cursor
 
 	header, _, err := r.ReadTagHeader()
@@ -116,27 +185,20 @@ func (r *Reader) FastPrepareIndex() (err error) {
 		return err
 	}
 
-	root := &IndexEntry{
-		Pos:       r.cursor,
-		Parent:    nil,
-		ListIndex: -1,
-		Header:    header,
-	}
-	r.Index[r.cursor] = root
+	ix := &indexer{Reader: r}
+	r.Index = Index{}
+	root := ix.add(-1, header, -1)
 
 	switch header.TagID {
 	case TagCompound:
-		if err = r.indexCompound(root, true, nil); err != nil {
-			return err
-		}
+		err = ix.indexCompound(root, true, nil)
 	case TagList:
-		if err = r.indexList(root, true, nil); err != nil {
-			return err
-		}
+		err = ix.indexList(root, true, nil)
 	default:
 		err = errors.New("nbt: invalid tag ID for fast prepare index, must be compound or list")
 	}
 
+	ix.end(root)
 	r.cursor = savedCursor
 	if err != nil {
 		return fmt.Errorf("nbt: error preparing index: %w", err)
@@ -144,7 +206,12 @@ func (r *Reader) FastPrepareIndex() (err error) {
 	return err
 }
 
-func (r *Reader) PrepareIndex() (err error) {
+// PrepareIndex indexes the tags of the data, which is required by the methods
+// that use the Index. Only the tags selected by sel are indexed, or every tag
+// if sel is nil. Index[0] is a synthetic compound entry at position 0 whose
+// child is the data's root compound. The index is only prepared once, later
+// calls do nothing.
+func (r *Reader) PrepareIndex(sel SelectiveIndex) (err error) {
 	if r.Index != nil {
 		return nil
 	}
@@ -155,22 +222,38 @@ func (r *Reader) PrepareIndex() (err error) {
 		}
 	}()
 
-	r.Index = make(map[int]*IndexEntry)
-
 	savedCursor := r.cursor
+	r.cursor = 0
+
+	ix := &indexer{Reader: r, sel: sel, last: []int32{-1}}
+	r.Index = Index{{
+		Parent:      -1,
+		FirstChild:  -1,
+		NextSibling: -1,
+		ListIndex:   -1,
+		Flags:       Flags(TagCompound) << (64 - 8),
+	}}
+
+	header, _, err := r.ReadTagHeader()
+	if err == nil && header.TagID != TagCompound {
+		err = fmt.Errorf("root tag must be a compound, got tag ID %d", header.TagID)
+	}
+
+	if err == nil {
+		// the root compound's name isn't part of paths
+		index := sel == nil || sel.Matches(header, nil)
+		root := 0
+		if index {
+			root = ix.add(0, header, -1)
+		}
 
-	root := &IndexEntry{
-		Pos:       0,
-		Parent:    nil,
-		ListIndex: -1,
-		Header: TagHeader{
-			TagID: TagCompound,
-			Name:  []byte("root"),
-		},
+		err = ix.indexCompound(root, index, nil)
+		if index {
+			ix.end(root)
+		}
 	}
-	r.Index[0] = root
 
-	err = r.indexCompound(root, index, false)
+	ix.end(0)
 	r.cursor = savedCursor
 	if err != nil {
 		return fmt.Errorf("nbt: error preparing index: %w", err)
@@ -178,111 +261,170 @@ func (r *Reader) PrepareIndex() (err error) {
 	return err
 }
 
-func (r *Reader) indexCompound(parent *IndexEntry, index bool, selectiveIndex SelectiveIndex) error {
+// indexer builds a reader's index. last holds the position of the last child
+// of each entry, so new children can be linked to their previous sibling.
+type indexer struct {
+	*Reader
+	sel  SelectiveIndex
+	last []int32
+}
+
+// add adds an entry for the tag with the given header whose payload is at the
+// cursor as the last child of parent, and returns its position in the index.
+// The length of its data is set by end once the cursor is after its payload.
+func (ix *indexer) add(parent int, header TagHeader, listIndex int) int {
+	var name Slice
+	if len(header.Name) > 0 {
+		name = NewSlice(ix.cursor-len(header.Name), len(header.Name))
+	}
+
+	i := len(ix.Index)
+	ix.Index = append(ix.Index, IndexEntry{
+		Name:        name,
+		Data:        NewSlice(ix.cursor, 0),
+		Parent:      int32(parent),
+		FirstChild:  -1,
+		NextSibling: -1,
+		ListIndex:   int32(listIndex),
+		Flags:       Flags(0).SetTagID(header.TagID),
+	})
+	ix.last = append(ix.last, -1)
+
+	if parent >= 0 {
+		if prev := ix.last[parent]; prev >= 0 {
+			ix.Index[prev].NextSibling = int32(i)
+		} else {
+			ix.Index[parent].FirstChild = int32(i)
+		}
+		ix.last[parent] = int32(i)
+	}
+
+	return i
+}
+
+// end sets the length of the entry's data to end at the cursor, and sets its
+// flags from its children.
+func (ix *indexer) end(i int) {
+	ent := &ix.Index[i]
+	if ent.Flags&FlagIsTag == 0 {
+		ent.Data = NewSlice(0, len(ix.data))
+		return
+	}
+
+	ent.Data = NewSlice(ent.Pos(), ix.cursor-ent.Pos())
+
+	if ent.TagID() != TagCompound {
+		return
+	}
+
+	var coords int
+	for c := ent.FirstChild; c >= 0; c = ix.Index[c].NextSibling {
+		child := &ix.Index[c]
+		switch string(ix.EntryName(child)) {
+		case "x", "y", "z":
+			if child.TagID() == TagInt {
+				coords++
+			}
+		case "Count":
+			if child.TagID() == TagByte {
+				ent.Flags |= FlagHasCount
+			}
+		}
+	}
+
+	if coords == 3 {
+		ent.Flags |= FlagHasCoords
+	}
+}
+
+// indexCompound indexes the tags of a compound. parent is the position in the
+// index of the compound's entry, or of its nearest indexed ancestor if it
+// isn't indexed itself.
+func (ix *indexer) indexCompound(parent int, index bool, path []byte) error {
 	for {
-		header, _, err := r.ReadTagHeader()
+		header, _, err := ix.ReadTagHeader()
 		if err == io.EOF {
 			return nil
 		}
 
 		if err != nil {
-			return fmt.Errorf("error reading tag header at position %d: %w", r.cursor, err)
+			return fmt.Errorf("error reading tag header at position %d: %w", ix.cursor, err)
 		}
 
 		if header.TagID == TagEnd {
 			return nil
 		}
 
-		shouldIndex := index
+		childPath := appendPath(path, header.Name)
 
-		if !shouldIndex {
-			shouldIndex = selectiveIndex.Matches(header)
-		}
-
-		ent := &IndexEntry{
-			Pos:       r.cursor,
-			ListIndex: -1,
-			Parent:    parent,
-			Header:    header,
-		}
+		shouldIndex := index || ix.sel.Matches(header, childPath)
 
+		ent := parent
 		if shouldIndex {
-			r.Index[r.cursor] = ent
-			if parent != nil {
-				parent.Children = append(parent.Children, ent)
-			}
+			ent = ix.add(parent, header, -1)
 		}
 
 		switch header.TagID {
 		case TagCompound:
-			if err := r.indexCompound(ent, shouldIndex, selectiveIndex); err != nil {
-				return err
-			}
+			err = ix.indexCompound(ent, shouldIndex, childPath)
 		case TagList:
-			if err := r.indexList(ent, shouldIndex, selectiveIndex); err != nil {
-				return err
-			}
+			err = ix.indexList(ent, shouldIndex, childPath)
 		default:
-			r.SkipTag(header.TagID)
+			ix.SkipTag(header.TagID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if shouldIndex {
+			ix.end(ent)
 		}
 	}
 }
 
-func (r *Reader) indexList(parent *IndexEntry, index bool, selectiveIndex SelectiveIndex) error {
-	tagID, length, unread := r.ReadListTagHeader()
+// indexList indexes the elements of a list, see indexCompound. Elements don't
+// have names, so compounds inside the list have the list's path.
+func (ix *indexer) indexList(parent int, index bool, path []byte) error {
+	tagID, length, unread := ix.ReadListTagHeader()
 	if tagID != TagCompound && tagID != TagList {
-		r.Unread(unread)
-		r.SkipTag(TagList)
+		ix.Unread(unread)
+		ix.SkipTag(TagList)
 		return nil
 	}
 
-	if tagID == TagCompound {
-		for i := 0; i < length; i++ {
-			ent := &IndexEntry{
-				Pos:       r.cursor,
-				ListIndex: i,
-				Parent:    parent,
-				Header: TagHeader{
-					TagID: tagID,
-					Name:  nil,
-				},
-			}
-
-			if index {
-				r.Index[r.cursor] = ent
-				if parent != nil {
-					parent.Children = append(parent.Children, ent)
-				}
-			}
+	for i := 0; i < length; i++ {
+		ent := parent
+		if index {
+			ent = ix.add(parent, TagHeader{TagID: tagID}, i)
+		}
 
-			if err := r.indexCompound(ent, index, selectiveIndex); err != nil {
-				return err
-			}
+		var err error
+		if tagID == TagCompound {
+			err = ix.indexCompound(ent, index, path)
+		} else {
+			err = ix.indexList(ent, index, path)
 		}
-	} else if tagID == TagList {
-		for i := 0; i < length; i++ {
-			ent := &IndexEntry{
-				Pos:       r.cursor,
-				ListIndex: i,
-				Parent:    parent,
-				Header: TagHeader{
-					TagID: tagID,
-					Name:  nil,
-				},
-			}
 
-			if index {
-				r.Index[r.cursor] = ent
-				if parent != nil {
-					parent.Children = append(parent.Children, ent)
-				}
-			}
+		if err != nil {
+			return err
+		}
 
-			if err := r.indexList(ent, index, selectiveIndex); err != nil {
-				return err
-			}
+		if index {
+			ix.end(ent)
 		}
 	}
 
 	return nil
 }
+
+func appendPath(path []byte, name []byte) []byte {
+	if len(path) == 0 {
+		return name
+	}
+
+	result := make([]byte, 0, len(path)+1+len(name))
+	result = append(result, path...)
+	result = append(result, '/')
+	return append(result, name...)
+}
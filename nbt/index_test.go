@@ -0,0 +1,193 @@
+package nbt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmpim/anvil"
+)
+
+func compoundPayload(children ...[]byte) []byte {
+	return append(bytes.Join(children, nil), byte(TagEnd))
+}
+
+func namedCompound(name string, children ...[]byte) []byte {
+	header := (&TagHeader{TagID: TagCompound, Name: []byte(name)}).Bytes()
+	return append(header, compoundPayload(children...)...)
+}
+
+func compoundList(name string, elements ...[]byte) []byte {
+	data := (&TagHeader{TagID: TagList, Name: []byte(name)}).Bytes()
+	n := len(elements)
+	data = append(data, byte(TagCompound), byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+
+	for _, elem := range elements {
+		data = append(data, elem...)
+	}
+
+	return data
+}
+
+// testChunkNBT returns a chunk with a chest containing a computer with the
+// given ID.
+func testChunkNBT(computerID int) []byte {
+	return namedCompound("",
+		NewIntTag("DataVersion", 2586).Bytes(),
+		namedCompound("Level",
+			NewIntTag("xPos", 1).Bytes(),
+			compoundList("TileEntities",
+				compoundPayload(
+					NewStringTag("id", "minecraft:chest").Bytes(),
+					NewIntTag("x", 20).Bytes(),
+					NewIntTag("y", 64).Bytes(),
+					NewIntTag("z", -5).Bytes(),
+					compoundList("Items",
+						compoundPayload(
+							NewStringTag("id", "computercraft:computer").Bytes(),
+							append((&TagHeader{TagID: TagByte, Name: []byte("Count")}).Bytes(), 2),
+							namedCompound("tag", NewIntTag("computerID", computerID).Bytes()),
+						),
+					),
+				),
+			),
+			compoundList("Entities"),
+		),
+	)
+}
+
+func indexedNames(r *Reader) map[string]int {
+	names := make(map[string]int)
+	for i := 1; i < len(r.Index); i++ {
+		names[string(r.EntryName(&r.Index[i]))]++
+	}
+	return names
+}
+
+func TestPrepareIndex(t *testing.T) {
+	rd := NewReader(testChunkNBT(5))
+	if !assert.NoError(t, rd.PrepareIndex(nil)) {
+		return
+	}
+
+	// every tag and list element, and the synthetic root
+	assert.Len(t, rd.Index, 18)
+	assert.Equal(t, 2, indexedNames(&rd)["id"])
+	assert.Equal(t, `{"root":{"":{"DataVersion":3,"Level":{"Entities":[],`+
+		`"TileEntities":[{"Items":[{"Count":1,"id":8,"tag":{"computerID":3}}],`+
+		`"id":8,"x":3,"y":3,"z":3}],"xPos":3}}}}`,
+		string(rd.StructureToJSON(0)))
+}
+
+func TestSelectiveIndex(t *testing.T) {
+	data := testChunkNBT(5)
+
+	rd := NewReader(data)
+	assert.NoError(t, rd.PrepareIndex(SelectiveIndex{
+		{TagID: TagList, Name: []byte("TileEntities")},
+	}))
+
+	names := indexedNames(&rd)
+	assert.Equal(t, 0, names["xPos"])
+	assert.Equal(t, 0, names["Entities"])
+	assert.Equal(t, 1, names["TileEntities"])
+	assert.Equal(t, 1, names["computerID"])
+
+	for i := range rd.Index {
+		if string(rd.EntryName(&rd.Index[i])) != "computerID" {
+			continue
+		}
+
+		var id int
+		rd.SeekTo(rd.Index[i].Pos())
+		rd.ReadImmediate(TagInt, &id)
+		assert.Equal(t, 5, id)
+
+		assert.Equal(t, &TileEntityDetails{
+			Location:  anvil.Coord{X: 20, Y: 64, Z: -5},
+			Container: true,
+			Count:     2,
+		}, rd.GetTileEntityDetails(&rd.Index[i]))
+	}
+
+	rd = NewReader(data)
+	assert.NoError(t, rd.PrepareIndex(SelectiveIndex{
+		{TagID: TagInt, Name: []byte("Level/xPos")},
+		{TagID: TagString, Name: []byte("Level/TileEntities/id")},
+		{TagID: TagInt, Name: []byte("DataVersion/x")},
+	}))
+
+	assert.Equal(t, map[string]int{"xPos": 1, "id": 1}, indexedNames(&rd))
+}
+
+func TestFlatIndex(t *testing.T) {
+	rd := NewReader(testChunkNBT(5))
+	assert.NoError(t, rd.PrepareIndex(SelectiveIndex{
+		{TagID: TagList, Name: []byte("TileEntities")},
+	}))
+
+	for i := 1; i < len(rd.Index); i++ {
+		assert.True(t, rd.Index[i-1].Pos() < rd.Index[i].Pos())
+
+		found, ok := rd.Index.Find(rd.Index[i].Pos())
+		assert.True(t, ok)
+		assert.Equal(t, i, found)
+		assert.Equal(t, i, rd.Index.Floor(rd.Index[i].Pos()+1))
+	}
+
+	// TileEntities' parents aren't indexed, so its parent is the root
+	tileEntities := rd.Index.Children(0)
+	if !assert.Len(t, tileEntities, 1) {
+		return
+	}
+	assert.Equal(t, "TileEntities", string(rd.EntryName(&rd.Index[tileEntities[0]])))
+
+	chest := rd.Index[tileEntities[0]].FirstChild
+	assert.Equal(t, int32(0), rd.Index[chest].ListIndex)
+	assert.Equal(t, FlagHasCoords, rd.Index[chest].Flags&(FlagHasCoords|FlagHasCount))
+
+	var names []string
+	for _, c := range rd.Index.Children(int(chest)) {
+		names = append(names, string(rd.EntryName(&rd.Index[c])))
+	}
+	assert.Equal(t, []string{"id", "x", "y", "z", "Items"}, names)
+
+	_, ok := rd.Index.Find(rd.Index[chest].Pos() + 1)
+	assert.False(t, ok)
+}
+
+func TestMatchTags(t *testing.T) {
+	computerID := (&TagHeader{TagID: TagInt, Name: []byte("computerID")}).Bytes()
+	x := (&TagHeader{TagID: TagInt, Name: []byte("x")}).Bytes()
+	unnamed := (&TagHeader{TagID: TagInt}).Bytes()
+
+	// the unnamed sibling has no name for its header to be found from
+	rd := NewReader(namedCompound("",
+		compoundList("Computers",
+			compoundPayload(
+				NewIntTag("", 1).Bytes(),
+				NewIntTag("computerID", 5).Bytes(),
+				NewIntTag("x", 3).Bytes(),
+			),
+			compoundPayload(
+				NewIntTag("computerID", 6).Bytes(),
+			),
+		),
+	))
+	if !assert.NoError(t, rd.PrepareIndex(nil)) {
+		return
+	}
+
+	results, err := rd.MatchTags([][]byte{computerID})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	results, err = rd.MatchTags([][]byte{computerID, x, unnamed})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		var id int
+		rd.SeekTo(rd.Index[results[0]].Pos())
+		rd.ReadImmediate(TagInt, &id)
+		assert.Equal(t, 5, id)
+	}
+}
@@ -0,0 +1,130 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tmpim/anvil"
+)
+
+const (
+	legacyBlocksSize = 16 * 16 * anvil.McRegionHeight
+	legacyDataSize   = legacyBlocksSize / 2
+)
+
+var ErrNotLegacyChunk = errors.New("nbt: chunk is not a McRegion chunk")
+
+// LegacyChunk is the block data of a chunk from a McRegion (.mcr) region file,
+// which are anvil.McRegionHeight blocks high.
+type LegacyChunk struct {
+	X int
+	Z int
+
+	// Blocks are the low 8 bits of the block IDs of the chunk indexed by
+	// y + z*128 + x*128*16, and Data are their 4 bit data values packed two
+	// per byte in the same order, starting with the low bits. Add holds the
+	// high 4 bits of block IDs above 255 packed like Data, and is nil if the
+	// chunk doesn't have it, which is only written by some mods.
+	Blocks []byte
+	Data   []byte
+	Add    []byte
+}
+
+// ReadLegacyChunk decodes the block data of a chunk read from a McRegion
+// region file. The returned Blocks and Data are slices of the decompressed
+// chunk data.
+func ReadLegacyChunk(c *anvil.ChunkData) (*LegacyChunk, error) {
+	if c.Format != anvil.FormatMcRegion {
+		return nil, ErrNotLegacyChunk
+	}
+
+	data, err := c.Decompress()
+	if err != nil {
+		return nil, err
+	}
+
+	// validating first means the unchecked reads below can't panic
+	if err := Validate(data); err != nil {
+		return nil, err
+	}
+
+	rd := NewReader(data)
+	rd.ReadTagHeader()
+
+	for {
+		header, _, _ := rd.ReadTagHeader()
+		if header.TagID == TagEnd {
+			return nil, fmt.Errorf("%w: missing Level compound", ErrNotLegacyChunk)
+		}
+
+		if header.TagID == TagCompound && string(header.Name) == "Level" {
+			return rd.readLegacyLevel()
+		}
+
+		rd.SkipTag(header.TagID)
+	}
+}
+
+func (r *Reader) readLegacyLevel() (*LegacyChunk, error) {
+	result := &LegacyChunk{}
+
+	for {
+		header, _, _ := r.ReadTagHeader()
+
+		switch {
+		case header.TagID == TagEnd:
+			if len(result.Blocks) != legacyBlocksSize {
+				return nil, fmt.Errorf("%w: Blocks has length %d, expected %d",
+					ErrNotLegacyChunk, len(result.Blocks), legacyBlocksSize)
+			}
+
+			if len(result.Data) != legacyDataSize {
+				return nil, fmt.Errorf("%w: Data has length %d, expected %d",
+					ErrNotLegacyChunk, len(result.Data), legacyDataSize)
+			}
+
+			if result.Add != nil && len(result.Add) != legacyDataSize {
+				return nil, fmt.Errorf("%w: Add has length %d, expected %d",
+					ErrNotLegacyChunk, len(result.Add), legacyDataSize)
+			}
+
+			return result, nil
+		case header.TagID == TagInt && string(header.Name) == "xPos":
+			r.ReadImmediate(TagInt, &result.X)
+		case header.TagID == TagInt && string(header.Name) == "zPos":
+			r.ReadImmediate(TagInt, &result.Z)
+		case header.TagID == TagByteArray && string(header.Name) == "Blocks":
+			r.ReadImmediate(TagByteArray, &result.Blocks)
+		case header.TagID == TagByteArray && string(header.Name) == "Data":
+			r.ReadImmediate(TagByteArray, &result.Data)
+		case header.TagID == TagByteArray && string(header.Name) == "Add":
+			r.ReadImmediate(TagByteArray, &result.Add)
+		default:
+			r.SkipTag(header.TagID)
+		}
+	}
+}
+
+// Block returns the block ID and data value of the block at the given
+// coordinates relative to the chunk, where x and z are from 0 to 15 and y is
+// from 0 to 127.
+func (c *LegacyChunk) Block(x, y, z int) (id int, data byte) {
+	i := y + z*anvil.McRegionHeight + x*anvil.McRegionHeight*16
+
+	id = int(c.Blocks[i])
+	if c.Add != nil {
+		id |= int(nibble(c.Add, i)) << 8
+	}
+
+	return id, nibble(c.Data, i)
+}
+
+// nibble returns the i-th 4 bit value of a nibble array, which are packed two
+// per byte starting with the low bits.
+func nibble(arr []byte, i int) byte {
+	if i&1 == 0 {
+		return arr[i>>1] & 0x0f
+	}
+
+	return arr[i>>1] >> 4
+}
@@ -0,0 +1,98 @@
+package nbt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmpim/anvil"
+)
+
+func byteArrayTag(name string, data []byte) []byte {
+	tag := (&TagHeader{TagID: TagByteArray, Name: []byte(name)}).Bytes()
+	n := len(data)
+	tag = append(tag, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	return append(tag, data...)
+}
+
+func legacyChunkData(children ...[]byte) *anvil.ChunkData {
+	return &anvil.ChunkData{
+		Format:      anvil.FormatMcRegion,
+		Compression: anvil.CompressionUncompressed,
+		Data:        namedCompound("", namedCompound("Level", children...)),
+	}
+}
+
+func TestReadLegacyChunk(t *testing.T) {
+	blocks := make([]byte, legacyBlocksSize)
+	data := make([]byte, legacyDataSize)
+	add := make([]byte, legacyDataSize)
+
+	// the blocks at y 10 and 11 share a byte of the nibble arrays, with the
+	// even index in the low bits
+	even := 10 + 2*anvil.McRegionHeight + 1*anvil.McRegionHeight*16
+	blocks[even], blocks[even+1] = 5, 7
+	data[even>>1] = 0xc3
+	add[even>>1] = 0x10
+
+	last := legacyBlocksSize - 1
+	blocks[last] = 0xff
+	data[last>>1] = 0xf0
+	add[last>>1] = 0xf0
+
+	level := [][]byte{
+		NewIntTag("xPos", -3).Bytes(),
+		NewIntTag("zPos", 4).Bytes(),
+		byteArrayTag("Blocks", blocks),
+		byteArrayTag("Data", data),
+		NewLongTag("LastUpdate", 100).Bytes(),
+	}
+
+	c, err := ReadLegacyChunk(legacyChunkData(level...))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, -3, c.X)
+	assert.Equal(t, 4, c.Z)
+	assert.Nil(t, c.Add)
+
+	block := func(x, y, z int) [2]int {
+		id, data := c.Block(x, y, z)
+		return [2]int{id, int(data)}
+	}
+
+	assert.Equal(t, [2]int{5, 3}, block(1, 10, 2))
+	assert.Equal(t, [2]int{7, 12}, block(1, 11, 2))
+	assert.Equal(t, [2]int{0, 0}, block(1, 12, 2))
+	assert.Equal(t, [2]int{255, 15}, block(15, 127, 15))
+
+	c, err = ReadLegacyChunk(legacyChunkData(append(level, byteArrayTag("Add", add))...))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, [2]int{5, 3}, block(1, 10, 2))
+	assert.Equal(t, [2]int{256 + 7, 12}, block(1, 11, 2))
+	assert.Equal(t, [2]int{0xfff, 15}, block(15, 127, 15))
+
+	_, err = ReadLegacyChunk(legacyChunkData(append(level, byteArrayTag("Add", add[1:]))...))
+	assert.True(t, errors.Is(err, ErrNotLegacyChunk))
+
+	_, err = ReadLegacyChunk(legacyChunkData(level[0], level[1],
+		byteArrayTag("Blocks", blocks[1:]), level[3]))
+	assert.True(t, errors.Is(err, ErrNotLegacyChunk))
+
+	_, err = ReadLegacyChunk(legacyChunkData(level[0], level[1], level[2]))
+	assert.True(t, errors.Is(err, ErrNotLegacyChunk))
+
+	noLevel := legacyChunkData()
+	noLevel.Data = namedCompound("", NewIntTag("xPos", 1).Bytes())
+	_, err = ReadLegacyChunk(noLevel)
+	assert.True(t, errors.Is(err, ErrNotLegacyChunk))
+
+	anvilChunk := legacyChunkData(level...)
+	anvilChunk.Format = anvil.FormatAnvil
+	_, err = ReadLegacyChunk(anvilChunk)
+	assert.Equal(t, ErrNotLegacyChunk, err)
+}
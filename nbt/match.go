@@ -0,0 +1,209 @@
+package nbt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Breadcrumb is a compound on the path from the root to a compound found by
+// SeekToMatch.
+type Breadcrumb struct {
+	// Name is the compound's name. Compounds in lists have no name, so it's
+	// the name of the list instead.
+	Name string
+	// ListIndex is the compound's index in its list, or -1 if it isn't in a
+	// list.
+	ListIndex int
+	// Pos is the position of the compound's header, or of its payload for
+	// compounds in lists, which have no header.
+	Pos int
+	// Payload is the position of the compound's payload.
+	Payload int
+}
+
+// Reader returns a copy of r with its cursor at the first header inside the
+// breadcrumb's compound. r must be the reader the breadcrumb was found in.
+func (b Breadcrumb) Reader(r *Reader) Reader {
+	return r.Copy(b.Payload)
+}
+
+// Breadcrumbs is the stack of compounds from the root to a compound found by
+// SeekToMatch, where the first breadcrumb is the closest to the root.
+type Breadcrumbs []Breadcrumb
+
+// Path returns the slash separated names of the compounds, with the indices
+// of compounds in lists, such as "Level/TileEntities[0]/Items[1]/tag".
+// Compounds without names, such as the root, aren't part of the path.
+func (b Breadcrumbs) Path() string {
+	var path strings.Builder
+
+	for _, crumb := range b {
+		if crumb.Name == "" && crumb.ListIndex < 0 {
+			continue
+		}
+
+		if path.Len() > 0 {
+			path.WriteByte('/')
+		}
+
+		path.WriteString(crumb.Name)
+		if crumb.ListIndex >= 0 {
+			path.WriteString("[" + strconv.Itoa(crumb.ListIndex) + "]")
+		}
+	}
+
+	return path.String()
+}
+
+// Last returns the last breadcrumb, which is the matching compound.
+func (b Breadcrumbs) Last() Breadcrumb {
+	return b[len(b)-1]
+}
+
+var boolType = reflect.TypeOf(false)
+
+// Matcher is a matcher function for SeekToMatch, which is called with the
+// values of the tags with the given names in a compound. Matchers are safe to
+// reuse across readers and goroutines.
+type Matcher struct {
+	names []string
+	types []reflect.Type
+	fn    reflect.Value
+}
+
+// NewMatcher returns a matcher calling fn, which must be a function returning
+// a bool with a parameter for each name. The types of the parameters are the
+// types the tags are read as, and tags with other types don't match. Pointer
+// parameters are optional, they are nil if the compound has no such tag, but
+// the compound must have every other tag to match.
+func NewMatcher(names []string, fn interface{}) (*Matcher, error) {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("nbt: matcher must be a function, got %T", fn)
+	}
+
+	typ := rv.Type()
+
+	if typ.NumOut() != 1 || typ.Out(0) != boolType {
+		return nil, fmt.Errorf("nbt: matcher must be a function returning a bool, got %v", typ)
+	}
+
+	if typ.NumIn() != len(names) || typ.IsVariadic() {
+		return nil, fmt.Errorf("nbt: matcher %v must have a parameter for each of the %d names",
+			typ, len(names))
+	}
+
+	m := &Matcher{
+		names: names,
+		types: make([]reflect.Type, len(names)),
+		fn:    rv,
+	}
+
+	for i := range names {
+		m.types[i] = typ.In(i)
+	}
+
+	return m, nil
+}
+
+// matches reads the tags of the compound whose payload is at the cursor and
+// returns whether it has all of the required tags with types matching the
+// matcher's parameters, and the matcher returns true for their values. The
+// cursor is left after the compound if it's read without errors.
+func (m *Matcher) matches(r *Reader) bool {
+	values := make([]reflect.Value, len(m.names))
+
+	for {
+		header, _, err := r.ReadTagHeader()
+		if err != nil {
+			return false
+		}
+
+		if header.TagID == TagEnd {
+			break
+		}
+
+		i := m.index(header)
+		if i < 0 || values[i].IsValid() {
+			r.SkipTag(header.TagID)
+			continue
+		}
+
+		value := reflect.New(m.types[i])
+		if _, err := r.ReadImmediate(header.TagID, value.Interface()); err != nil {
+			return false
+		}
+
+		values[i] = value.Elem()
+	}
+
+	for i, value := range values {
+		if value.IsValid() {
+			continue
+		}
+
+		if m.types[i].Kind() != reflect.Ptr {
+			return false
+		}
+
+		values[i] = reflect.Zero(m.types[i])
+	}
+
+	return m.fn.Call(values)[0].Bool()
+}
+
+// index returns the index of the matcher's parameter for the tag, or -1 if it
+// isn't one of the names or doesn't match the parameter's type.
+func (m *Matcher) index(header TagHeader) int {
+	for i, name := range m.names {
+		if string(header.Name) == name && tagMatchesType(header.TagID, m.types[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// tagMatchesType returns whether a tag with the given ID can be read into a
+// value of the given type by ReadImmediate.
+func tagMatchesType(tagID TagID, typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		return typ.NumMethod() == 0 && tagID != TagEnd
+	case reflect.Struct, reflect.Map:
+		return tagID == TagCompound
+	}
+
+	switch tagID {
+	case TagByte:
+		return typ.Kind() == reflect.Uint8
+	case TagShort:
+		return typ.Kind() == reflect.Int16
+	case TagInt:
+		return typ.Kind() == reflect.Int
+	case TagLong:
+		return typ.Kind() == reflect.Int64
+	case TagFloat:
+		return typ.Kind() == reflect.Float32
+	case TagDouble:
+		return typ.Kind() == reflect.Float64
+	case TagString:
+		return typ.Kind() == reflect.String
+	case TagByteArray:
+		return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+	case TagIntArray:
+		return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Int
+	case TagLongArray:
+		return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Int64
+	case TagList:
+		return typ.Kind() == reflect.Slice
+	}
+
+	return false
+}
@@ -25,6 +25,10 @@ var (
 type Reader struct {
 	data   []byte
 	cursor int
+
+	// Index is the index of the data's tags, which is nil until PrepareIndex
+	// is called.
+	Index Index
 }
 
 func NewGzipReader(rd io.Reader) (Reader, error) {
@@ -97,27 +101,24 @@ func (r *Reader) SeekTo(pos int) {
 // AlignToIndex seeks up until the cursor is aligned to a valid index entry.
 // Returns nil if there is no index, or if it hits the start of the chunk data
 // without finding any valid index entries.
-// func (r *Reader) AlignToIndex() *IndexEntry {
-// 	if r.Index == nil {
-// 		return nil
-// 	}
-
-// 	for i := r.cursor; i >= 0; i-- {
-// 		if ent, found := r.Index[i]; found {
-// 			r.SeekTo(i)
-// 			return ent
-// 		}
-// 	}
+func (r *Reader) AlignToIndex() *IndexEntry {
+	for i := r.Index.Floor(r.cursor); i >= 0; i-- {
+		// skip the synthetic root, which isn't a tag
+		if r.Index[i].Flags&FlagIsTag != 0 {
+			r.SeekTo(r.Index[i].Pos())
+			return &r.Index[i]
+		}
+	}
 
-// 	return nil
-// }
+	return nil
+}
 
 // SeekToAndRead seeks to the given name and a tag ID matching the type of `value`
 // and reads it into `value`. SeekToAndRead will stop if it reaches the end of
 // the current compound, it will leave the cursor pointing to the next header after TagEnd
 // and it will return ErrEndOfCompound. If the end of the NBT is reached, io.EOF is returned.
 // SeekToAndRead will not recursively
-// search through compounds, use SeekToMatchingCompound and ReadCompound for that.
+// search through compounds, use RecurSeekToMatchingCompound for that.
 //
 // Returns the number of bytes to unread to restore the reader's state
 // back to before SeekToAndRead was called if the tag with a name and a tag ID
@@ -125,32 +126,165 @@ func (r *Reader) SeekTo(pos int) {
 //
 // If the tag could be found with the matching name and value, it will return
 // the number of bytes to unread back to the header of the matching tag.
-// func (r *Reader) SeekToAndRead(name string, value interface{}) (int, error) {
+func (r *Reader) SeekToAndRead(name string, value interface{}) (int, error) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return 0, fmt.Errorf("%w a non-nil pointer", ErrInvalidType)
+	}
 
-// }
+	start := r.cursor
+
+	for {
+		headerStart := r.cursor
+
+		header, _, err := r.ReadTagHeader()
+		if err != nil {
+			return r.cursor - start, err
+		}
+
+		if header.TagID == TagEnd {
+			return r.cursor - start, ErrEndOfCompound
+		}
+
+		if string(header.Name) != name || !tagMatchesType(header.TagID, rv.Elem().Type()) {
+			r.SkipTag(header.TagID)
+			continue
+		}
+
+		if _, err := r.ReadImmediate(header.TagID, value); err != nil {
+			return r.cursor - start, err
+		}
+
+		return r.cursor - headerStart, nil
+	}
+}
 
 // RecurSeekToMatchingCompound will search recursively for the first compound
 // whose keys match the provided matcher. The names correlate to tag names in order
 // of parameters of the given matcher function, the input types of the matcher
 // function determines the type those tags will be decoded as and provided
-// to the matcher function.
+// to the matcher function, see NewMatcher.
 // If the matcher function returns true, the cursor will stop at the first header
-// inside the matching compound and will return a stack of readers that represent
-// the start of the stack of compounds found, pointing to the header of the compound, where
-// readers[0] is the reader closest to the known root, and 0 is returned.
+// inside the matching compound and will return the breadcrumbs of the stack of
+// compounds found, where breadcrumbs[0] is the compound closest to the known root,
+// and 0 is returned.
 // If no match is ever found, the number of bytes to unread back to the to go back
 // to the state before SeekToMatchingCompound was called is returned as the int and the
 // reader will be left in an EOF state.
-// func (r *Reader) RecurSeekToMatchingCompound(names []string, matcher interface{}) ([]Breadcrumb, int) {
+//
+// RecurSeekToMatchingCompound panics if the matcher isn't a function returning
+// a bool with a parameter for each name. Use NewMatcher and SeekToMatch to
+// reuse a matcher, or to handle invalid matchers.
+func (r *Reader) RecurSeekToMatchingCompound(names []string, matcher interface{}) (Breadcrumbs, int) {
+	m, err := NewMatcher(names, matcher)
+	if err != nil {
+		panic(err)
+	}
+
+	return r.SeekToMatch(m)
+}
+
+// SeekToMatch is RecurSeekToMatchingCompound with a matcher created by
+// NewMatcher.
+func (r *Reader) SeekToMatch(m *Matcher) (Breadcrumbs, int) {
+	start := r.cursor
+
+	for {
+		headerStart := r.cursor
+
+		header, _, err := r.ReadTagHeader()
+		if err != nil {
+			break
+		}
+
+		// the end of a compound the search started in
+		if header.TagID == TagEnd {
+			continue
+		}
 
-// }
+		crumb := Breadcrumb{
+			Name:      string(header.Name),
+			ListIndex: -1,
+			Pos:       headerStart,
+		}
+
+		if crumbs, found := r.recurSeek(header.TagID, crumb, m, nil); found {
+			return crumbs, 0
+		}
+	}
+
+	r.cursor = len(r.data)
+	return nil, r.cursor - start
+}
+
+// recurSeek searches the tag whose header has just been read for a compound
+// matching m. crumb is the breadcrumb of the tag, which is added to crumbs if
+// it's a compound.
+func (r *Reader) recurSeek(tagID TagID, crumb Breadcrumb, m *Matcher,
+	crumbs Breadcrumbs) (Breadcrumbs, bool) {
+	switch tagID {
+	case TagCompound:
+		crumb.Payload = r.cursor
+		crumbs = append(crumbs, crumb)
+
+		matched := m.matches(r)
+		r.cursor = crumb.Payload
+
+		if matched {
+			return crumbs, true
+		}
+
+		for {
+			headerStart := r.cursor
+
+			header, _, _ := r.ReadTagHeader()
+			if header.TagID == TagEnd {
+				return nil, false
+			}
+
+			child := Breadcrumb{
+				Name:      string(header.Name),
+				ListIndex: -1,
+				Pos:       headerStart,
+			}
+
+			if result, found := r.recurSeek(header.TagID, child, m, crumbs); found {
+				return result, true
+			}
+		}
+	case TagList:
+		elemTag, length, unread := r.ReadListTagHeader()
+		if elemTag != TagCompound && elemTag != TagList {
+			r.Unread(unread)
+			r.SkipTag(TagList)
+			return nil, false
+		}
+
+		// elements have no names, so they have the list's name
+		for i := 0; i < length; i++ {
+			elem := Breadcrumb{
+				Name:      crumb.Name,
+				ListIndex: i,
+				Pos:       r.cursor,
+			}
+
+			if result, found := r.recurSeek(elemTag, elem, m, crumbs); found {
+				return result, true
+			}
+		}
+	default:
+		r.SkipTag(tagID)
+	}
+
+	return nil, false
+}
 
 func (r *Reader) VerifyTagHeader() error {
 	if r.Index == nil {
 		return ErrNotIndexed
 	}
 
-	if _, found := r.Index[r.cursor]; !found {
+	if _, found := r.Index.Find(r.cursor); !found {
 		return ErrInvalidHeaderLocation
 	}
 
@@ -193,6 +327,9 @@ func (r *Reader) readCompound(value interface{}) (int, error) {
 
 			val, ok := resultMap[string(header.Name)]
 			if !ok {
+				start := r.cursor
+				r.SkipTag(header.TagID)
+				totalUnread += r.cursor - start
 				continue
 			}
 
@@ -201,14 +338,16 @@ func (r *Reader) readCompound(value interface{}) (int, error) {
 			if err != nil {
 				return totalUnread, err
 			}
-
-			underlying.SetMapIndex(reflect.ValueOf(string(header.Name)), reflect.ValueOf(val).Elem())
 		}
 
 		return totalUnread, nil
 	case reflect.Map:
 		if underlying.Type().Key().Kind() != reflect.String {
-			return 0, fmt.Errorf("%w map key must be a pointer")
+			return 0, fmt.Errorf("%w map with string keys", ErrInvalidType)
+		}
+
+		if underlying.IsNil() {
+			underlying.Set(reflect.MakeMap(underlying.Type()))
 		}
 
 		for {
@@ -229,12 +368,13 @@ func (r *Reader) readCompound(value interface{}) (int, error) {
 				return totalUnread, err
 			}
 
-			underlying.SetMapIndex(reflect.ValueOf(header.Name), result.Elem())
+			key := reflect.ValueOf(string(header.Name)).Convert(underlying.Type().Key())
+			underlying.SetMapIndex(key, result.Elem())
 		}
 
 		return totalUnread, nil
 	default:
-		return 0, fmt.Errorf("%w a pointer to a struct or map")
+		return 0, fmt.Errorf("%w a pointer to a struct or map", ErrInvalidType)
 	}
 
 }
@@ -275,20 +415,30 @@ func createType(tagID TagID) interface{} {
 func (r *Reader) ReadImmediate(tagID TagID, value interface{}) (int, error) {
 	rv := reflect.ValueOf(value)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		return 0, fmt.Errorf("a non-nil pointer", ErrInvalidType)
+		return 0, fmt.Errorf("%w a non-nil pointer", ErrInvalidType)
+	}
+
+	if tagID == TagEnd {
+		return 0, ErrEndOfCompound
 	}
 
 	// pointer in pointer, create a new value for it and redirect it
 	if rv.Elem().Kind() == reflect.Ptr {
-		newValue := reflect.New(rv.Elem().Elem().Type())
+		newValue := reflect.New(rv.Elem().Type().Elem())
 		rv.Elem().Set(newValue)
-		rv = newValue.Addr()
+		rv = newValue
+		value = rv.Interface()
 	}
 
+	// an empty interface, read into a value of the tag's natural type and
+	// store that in the interface
 	if rv.Elem().Kind() == reflect.Interface && rv.Elem().NumMethod() == 0 {
-		rv.Elem().Set(reflect.ValueOf(createType(tagID)))
-		rv = rv.Elem().Elem().Addr()
-		value = rv.Interface()
+		newValue := reflect.New(reflect.TypeOf(createType(tagID)))
+		n, err := r.ReadImmediate(tagID, newValue.Interface())
+		if err == nil {
+			rv.Elem().Set(newValue.Elem())
+		}
+		return n, err
 	}
 
 	switch tagID {
@@ -504,7 +654,10 @@ func (r *Reader) PossibleTagMatch(patterns [][][]byte) (bool, error) {
 	return true, nil
 }
 
-func (r *Reader) MatchTags(headerGroup [][]byte) ([]*IndexEntry, error) {
+// MatchTags returns the positions in the index of the tags whose header is
+// headerGroup[0] and whose parent also has tags with each of the other
+// headers in headerGroup.
+func (r *Reader) MatchTags(headerGroup [][]byte) ([]int, error) {
 	if r.Index == nil {
 		return nil, ErrNotIndexed
 	}
@@ -515,7 +668,7 @@ func (r *Reader) MatchTags(headerGroup [][]byte) ([]*IndexEntry, error) {
 	}()
 
 	r.cursor = 0
-	var results []*IndexEntry
+	var results []int
 
 	for {
 		nextPos := bytes.Index(r.data[r.cursor:], headerGroup[0])
@@ -531,43 +684,44 @@ func (r *Reader) MatchTags(headerGroup [][]byte) ([]*IndexEntry, error) {
 			continue
 		}
 
-		meta, found := r.Index[r.cursor]
+		i, found := r.Index.Find(r.cursor)
 		if !found {
 			log.Println("warning: matching tag not in index:", r.cursor)
 			continue
 		}
 
-		if meta.Parent == nil {
+		parent := r.Index[i].Parent
+		if parent < 0 {
 			return nil, ErrIndexCorrupt
 		}
 
 		headerChecks := make([][]byte, len(headerGroup)-1)
 		copy(headerChecks, headerGroup[1:])
 
-		if len(headerChecks) > 0 {
-			for _, child := range meta.Parent.Children {
-				if child.Pos == r.cursor || child.ListIndex >= 0 {
-					continue
-				}
+		for c := r.Index[parent].FirstChild; c >= 0 && len(headerChecks) > 0; c = r.Index[c].NextSibling {
+			child := &r.Index[c]
+			if int(c) == i || child.ListIndex >= 0 {
+				continue
+			}
 
-				childPos := child.Pos - child.Header.Length()
+			// the header starts with the tag ID and the name's length
+			childPos := child.Data.Pos() - 3 - child.Name.Len()
 
-				for i, matchTo := range headerChecks {
-					if len(r.data)-childPos < len(matchTo) {
-						continue
-					}
+			for j, matchTo := range headerChecks {
+				if len(r.data)-childPos < len(matchTo) {
+					continue
+				}
 
-					if bytes.Equal(r.data[childPos:childPos+len(matchTo)], matchTo) {
-						headerChecks[i] = headerChecks[len(headerChecks)-1]
-						headerChecks = headerChecks[:len(headerChecks)-1]
-						break
-					}
+				if bytes.Equal(r.data[childPos:childPos+len(matchTo)], matchTo) {
+					headerChecks[j] = headerChecks[len(headerChecks)-1]
+					headerChecks = headerChecks[:len(headerChecks)-1]
+					break
 				}
 			}
 		}
 
 		if len(headerChecks) == 0 {
-			results = append(results, r.Index[r.cursor])
+			results = append(results, i)
 		}
 	}
 
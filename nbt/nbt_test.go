@@ -0,0 +1,209 @@
+package nbt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmpim/anvil"
+)
+
+func TestAlignToIndex(t *testing.T) {
+	data := testChunkNBT(5)
+	rd := NewReader(data)
+	assert.Nil(t, rd.AlignToIndex())
+
+	assert.NoError(t, rd.PrepareIndex(SelectiveIndex{
+		{TagID: TagList, Name: []byte("TileEntities")},
+	}))
+
+	// before the first indexed tag there's only the synthetic root
+	rd.SeekTo(5)
+	assert.Nil(t, rd.AlignToIndex())
+	assert.Equal(t, 5, rd.Cursor())
+
+	rd.SeekTo(bytes.Index(data, []byte("computercraft:")) + 3)
+	ent := rd.AlignToIndex()
+	if !assert.NotNil(t, ent) {
+		return
+	}
+
+	assert.Equal(t, "id", string(rd.EntryName(ent)))
+	assert.Equal(t, ent.Pos(), rd.Cursor())
+
+	var id string
+	rd.ReadImmediate(ent.TagID(), &id)
+	assert.Equal(t, "computercraft:computer", id)
+
+	assert.Equal(t, &TileEntityDetails{
+		Location:  anvil.Coord{X: 20, Y: 64, Z: -5},
+		Container: true,
+		Count:     2,
+	}, rd.GetTileEntityDetails(ent))
+}
+
+func TestSeekToAndRead(t *testing.T) {
+	data := testChunkNBT(5)
+	rd := NewReader(data)
+
+	// inside the root compound
+	rd.ReadTagHeader()
+	start := rd.Cursor()
+
+	var level map[string]interface{}
+	unread, err := rd.SeekToAndRead("Level", &level)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, level["xPos"])
+	assert.Len(t, level["TileEntities"], 1)
+
+	// unreading returns to the Level header
+	rd.Unread(unread)
+	header, _, _ := rd.ReadTagHeader()
+	assert.Equal(t, "Level", string(header.Name))
+
+	var xPos int
+	_, err = rd.SeekToAndRead("xPos", &xPos)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, xPos)
+
+	// the tag has to have a matching type
+	beforeMissing := rd.Cursor()
+	var name string
+	unread, err = rd.SeekToAndRead("xPos", &name)
+	assert.Equal(t, ErrEndOfCompound, err)
+	assert.Equal(t, data[rd.Cursor()-1], byte(TagEnd))
+	rd.Unread(unread)
+	assert.Equal(t, beforeMissing, rd.Cursor())
+
+	// DataVersion is in the root compound, not Level
+	_, err = rd.SeekToAndRead("DataVersion", &xPos)
+	assert.Equal(t, ErrEndOfCompound, err)
+	_, err = rd.SeekToAndRead("DataVersion", &xPos)
+	assert.Equal(t, ErrEndOfCompound, err)
+	_, err = rd.SeekToAndRead("DataVersion", &xPos)
+	assert.Equal(t, io.EOF, err)
+
+	rd.SeekTo(start)
+	var version *int
+	_, err = rd.SeekToAndRead("DataVersion", &version)
+	assert.NoError(t, err)
+	assert.Equal(t, 2586, *version)
+
+	var tileEntities []struct {
+		ID   string `nbt:"id"`
+		X, Y int    `nbt:"-"`
+		Z    int    `nbt:"z"`
+	}
+	rd.SeekTo(bytes.Index(data, []byte("Level")) + len("Level"))
+	_, err = rd.SeekToAndRead("TileEntities", &tileEntities)
+	assert.NoError(t, err)
+	if assert.Len(t, tileEntities, 1) {
+		assert.Equal(t, "minecraft:chest", tileEntities[0].ID)
+		assert.Equal(t, -5, tileEntities[0].Z)
+		assert.Equal(t, 0, tileEntities[0].X)
+	}
+}
+
+func TestRecurSeekToMatchingCompound(t *testing.T) {
+	data := testChunkNBT(5)
+	rd := NewReader(data)
+
+	crumbs, unread := rd.RecurSeekToMatchingCompound([]string{"x", "y", "z", "id"},
+		func(x, y, z int, id string) bool {
+			return id == "minecraft:chest" && y > 0
+		})
+	assert.Equal(t, 0, unread)
+
+	// the root, Level and the chest in TileEntities
+	if !assert.Len(t, crumbs, 3) {
+		return
+	}
+
+	assert.Equal(t, Breadcrumb{Name: "", ListIndex: -1, Pos: 0, Payload: 3}, crumbs[0])
+	assert.Equal(t, "Level", crumbs[1].Name)
+	assert.Equal(t, "Level/TileEntities[0]", crumbs.Path())
+	assert.Equal(t, crumbs[2].Pos, crumbs[2].Payload)
+
+	chest := crumbs.Last().Reader(&rd)
+	var x int
+	_, err := chest.SeekToAndRead("x", &x)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, x)
+
+	// the cursor is at the first header of the chest
+	header, n, _ := rd.ReadTagHeader()
+	assert.Equal(t, "id", string(header.Name))
+	rd.Unread(n)
+
+	// searching from inside the chest only finds the item and its tag
+	crumbs, unread = rd.RecurSeekToMatchingCompound([]string{"computerID"},
+		func(id int) bool { return id == 5 })
+	assert.Equal(t, 0, unread)
+	assert.Len(t, crumbs, 2)
+
+	rd.SeekTo(0)
+	crumbs, _ = rd.RecurSeekToMatchingCompound([]string{"computerID"},
+		func(id int) bool { return id == 5 })
+	assert.Len(t, crumbs, 5)
+	assert.Equal(t, "Level/TileEntities[0]/Items[0]/tag", crumbs.Path())
+
+	// a matcher which never matches leaves the reader at the end
+	rd.SeekTo(0)
+	crumbs, unread = rd.RecurSeekToMatchingCompound([]string{"computerID"},
+		func(id int) bool { return id == 6 })
+	assert.Nil(t, crumbs)
+	assert.Equal(t, len(data), unread)
+	assert.Equal(t, len(data), rd.Cursor())
+
+	// as does one with types that don't match
+	rd.SeekTo(0)
+	crumbs, _ = rd.RecurSeekToMatchingCompound([]string{"computerID"},
+		func(id string) bool { return true })
+	assert.Nil(t, crumbs)
+
+	assert.Panics(t, func() {
+		rd.RecurSeekToMatchingCompound([]string{"x", "y"}, func(x int) bool { return true })
+	})
+	assert.Panics(t, func() {
+		rd.RecurSeekToMatchingCompound([]string{"x"}, func(x int) {})
+	})
+}
+
+func TestSeekToMatch(t *testing.T) {
+	// the computer in the chest is the only compound with coordinates and an
+	// ID, but optional parameters match the chest without one
+	m, err := NewMatcher([]string{"x", "y", "z", "computerID"},
+		func(x, y, z int, computerID *int) bool {
+			return computerID == nil && y == 64
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, id := range []int{5, 6} {
+		rd := NewReader(testChunkNBT(id))
+		crumbs, unread := rd.SeekToMatch(m)
+		assert.Equal(t, 0, unread)
+		assert.Equal(t, "Level/TileEntities[0]", crumbs.Path())
+	}
+
+	m, err = NewMatcher([]string{"id", "Count"}, func(id string, count *byte) bool {
+		return count != nil && *count == 2
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rd := NewReader(testChunkNBT(5))
+	crumbs, _ := rd.SeekToMatch(m)
+	assert.Equal(t, "Level/TileEntities[0]/Items[0]", crumbs.Path())
+	assert.Equal(t, 0, crumbs.Last().ListIndex)
+
+	_, err = NewMatcher([]string{"x"}, 5)
+	assert.Error(t, err)
+	_, err = NewMatcher([]string{"x"}, func(x int) int { return x })
+	assert.Error(t, err)
+	_, err = NewMatcher([]string{"x", "y"}, func(x ...int) bool { return true })
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidNBT = errors.New("nbt: invalid nbt")
+
+// Validate checks that data is a single well formed NBT compound, such as
+// the decompressed data of a chunk. It is suitable for use as
+// anvil.CheckOptions.Validate.
+func Validate(data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidNBT, r)
+		}
+	}()
+
+	rd := NewReader(data)
+	header, _, err := rd.ReadTagHeader()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidNBT, err)
+	}
+
+	if header.TagID != TagCompound {
+		return fmt.Errorf("%w: root tag must be a compound, got tag ID %d",
+			ErrInvalidNBT, header.TagID)
+	}
+
+	return rd.validateTag(TagCompound)
+}
+
+// validateTag is a stricter SkipTag which returns an error instead of
+// stopping at the end of the data or panicking on invalid tag IDs.
+func (r *Reader) validateTag(tagID TagID) error {
+	switch tagID {
+	case TagByte, TagShort, TagInt, TagFloat, TagLong, TagDouble, TagString:
+		r.cursor += r.SimpleTagSize(tagID)
+	case TagByteArray, TagIntArray, TagLongArray:
+		// lengths are signed, a negative length isn't just a large one
+		if int32(r.readInt()) < 0 {
+			return fmt.Errorf("%w: negative array length at position %d", ErrInvalidNBT, r.cursor)
+		}
+
+		r.cursor -= 4
+		r.cursor += r.SimpleTagSize(tagID)
+	case TagList:
+		elemTag, length, _ := r.ReadListTagHeader()
+		if int32(length) < 0 {
+			return fmt.Errorf("%w: negative list length at position %d", ErrInvalidNBT, r.cursor)
+		}
+
+		if length > 0 && elemTag == TagEnd {
+			return fmt.Errorf("%w: list of end tags at position %d", ErrInvalidNBT, r.cursor)
+		}
+
+		for i := 0; i < length; i++ {
+			if err := r.validateTag(elemTag); err != nil {
+				return err
+			}
+		}
+	case TagCompound:
+		for {
+			header, _, err := r.ReadTagHeader()
+			if err != nil {
+				return fmt.Errorf("%w: unterminated compound: %v", ErrInvalidNBT, err)
+			}
+
+			if header.TagID == TagEnd {
+				break
+			}
+
+			if err := r.validateTag(header.TagID); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: invalid tag ID %d at position %d", ErrInvalidNBT,
+			tagID, r.cursor)
+	}
+
+	if r.cursor > len(r.data) {
+		return fmt.Errorf("%w: unexpected end of data", ErrInvalidNBT)
+	}
+
+	return nil
+}
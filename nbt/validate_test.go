@@ -0,0 +1,41 @@
+package nbt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	data := testChunkNBT(5)
+	assert.NoError(t, Validate(data))
+
+	// every truncation of a valid chunk is invalid
+	for n := 0; n < len(data); n++ {
+		assert.True(t, errors.Is(Validate(data[:n]), ErrInvalidNBT), "truncated to %d bytes", n)
+	}
+
+	assert.True(t, errors.Is(Validate(NewIntTag("root", 1).Bytes()), ErrInvalidNBT))
+
+	intArray := (&TagHeader{TagID: TagIntArray, Name: []byte("a")}).Bytes()
+	list := (&TagHeader{TagID: TagList, Name: []byte("l")}).Bytes()
+
+	invalid := map[string][]byte{
+		"invalid tag ID":    {13, 0, 1, 'x', 0},
+		"negative array":    append(intArray, 0xff, 0xff, 0xff, 0xfc),
+		"truncated array":   append(intArray, 0, 0, 0, 2, 0, 0, 0, 1),
+		"negative list":     append(list, byte(TagByte), 0x80, 0, 0, 0),
+		"list of end tags":  append(list, byte(TagEnd), 0, 0, 0, 1),
+		"invalid list type": append(list, 13, 0, 0, 0, 1),
+		"truncated string":  {byte(TagString), 0, 1, 's', 0, 5, 'a', 'b'},
+	}
+
+	for name, tag := range invalid {
+		err := Validate(namedCompound("", tag))
+		assert.True(t, errors.Is(err, ErrInvalidNBT), "%s: %v", name, err)
+	}
+
+	// empty lists of end tags are how empty lists are usually written
+	assert.NoError(t, Validate(namedCompound("", append(list, byte(TagEnd), 0, 0, 0, 0))))
+}
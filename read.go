@@ -10,140 +10,466 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/klauspost/compress/zlib"
 	"github.com/minio/highwayhash"
 )
 
 const (
 	sectorShift = 12
+
+	// headerSize is the size of the location table followed by the
+	// timestamp table at the start of every region file.
+	headerSize      = 8192
+	timestampOffset = 4096
+
+	// externalFlag is set on the compression type byte of chunks which are
+	// too large to fit in a region file, and are stored in a separate
+	// c.<x>.<z>.mcc file in the same directory as the region instead.
+	externalFlag = 0x80
 )
 
 var hashKey = []byte("\x8f\x7f\x9e\x63\x9f\x74\x8a\xc3\xe4\x21\xe8\xda\x7a\x7e\xbc\x12\x3a\xec\x2e\x15\xc4\xf4\x7d\x18\x8c\x7e\x2d\xf0\x86\x01\x26\xd9")
 
 type ChunkData struct {
 	Chunk Chunk
-	Data  []byte
+	// Format is the format of the region the chunk was read from, which
+	// determines the layout of its NBT data.
+	Format RegionFormat
+	// Dimension is the name of the dimension the chunk is from, and Kind the
+	// region folder it was read from, if it was read from a World.
+	Dimension   string
+	Kind        RegionKind
+	Compression CompressionType
+	Data        []byte
+	// External is true if the chunk was stored in an external .mcc file.
+	External bool
+	// LastModified is the time the chunk was last saved, according to the
+	// region's timestamp table.
+	LastModified time.Time
 }
 
+// ChunkTimestamp is an entry in the timestamp table of a region file.
+type ChunkTimestamp struct {
+	Chunk        Chunk
+	LastModified time.Time
+}
+
+var ErrExternalUnavailable = errors.New("anvil: external chunks are not available for this region")
+
 type RegionReader struct {
 	Region Region
+	// Format is the format of the region file, determined from the filename
+	// for regions opened from a file.
+	Format RegionFormat
+
+	// ReadExternal reads the data of chunks stored outside of the region
+	// file. For regions opened from a file it reads the .mcc file next to
+	// the region, otherwise it returns ErrExternalUnavailable unless set.
+	ReadExternal func(chunk Chunk) ([]byte, error)
+
 	header []byte
-	file   *os.File
+	src    io.ReaderAt
+	size   int64
+
+	// file and dir are only set for regions opened from a file.
+	file *os.File
+	dir  string
+
+	// mapped is the memory mapped region file for readers opened with
+	// OpenRegionFileMapped.
+	mapped []byte
+
+	// linear are the uncompressed chunks of a .linear region, by their
+	// index in the location table.
+	linear [][]byte
 }
 
 func (c *ChunkData) Hash() [highwayhash.Size128]byte {
 	return highwayhash.Sum128(c.Data, hashKey)
 }
 
+// compression returns the compression type of the chunk, defaulting to zlib
+// for chunks constructed without one.
+func (c *ChunkData) compression() CompressionType {
+	if c.Compression == 0 {
+		return CompressionZlib
+	}
+	return c.Compression
+}
+
+// NewReader returns a reader over the decompressed chunk data. Errors
+// decompressing the chunk are returned as a *ChunkError wrapping
+// ErrUnknownCompression or ErrDecompression, both from NewReader and from
+// reading.
+func (c *ChunkData) NewReader() (io.ReadCloser, error) {
+	rd, err := c.compression().NewReader(bytes.NewReader(c.Data))
+	if err != nil {
+		return nil, c.decompressionError(err)
+	}
+
+	return &chunkReader{ReadCloser: rd, c: c}, nil
+}
+
+// Decompress decompresses the chunk data, see NewReader.
 func (c *ChunkData) Decompress() ([]byte, error) {
-	rd, err := zlib.NewReader(bytes.NewReader(c.Data))
+	data, err := c.compression().Decompress(c.Data)
 	if err != nil {
-		return nil, err
+		return nil, c.decompressionError(err)
 	}
-	defer rd.Close()
-	return ioutil.ReadAll(rd)
+
+	return data, nil
+}
+
+func (c *ChunkData) decompressionError(err error) error {
+	if errors.Is(err, ErrUnknownCompression) {
+		return newChunkError(c.Chunk, err)
+	}
+
+	return newChunkError(c.Chunk, fmt.Errorf("%w: %v", ErrDecompression, err))
+}
+
+// chunkReader wraps errors decompressing a chunk in a *ChunkError.
+type chunkReader struct {
+	io.ReadCloser
+	c *ChunkData
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = r.c.decompressionError(err)
+	}
+
+	return n, err
 }
 
 func OpenRegionFile(filename string) (*RegionReader, error) {
-	region, err := validateFilename(filename)
+	region, err := ParseRegionFilename(filename)
 	if err != nil {
 		return nil, fmt.Errorf("anvil: not a valid region filename: %w", err)
 	}
 
+	if regionFileFormat(filename) == FormatLinear {
+		return openLinearRegionFile(filename, region)
+	}
+
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	header := make([]byte, 4096)
-	_, err = io.ReadFull(f, header)
+	rd, err := newRegionReader(f, region, filename)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return rd, nil
+}
+
+func newRegionReader(f *os.File, region Region, filename string) (*RegionReader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := NewRegionReader(f, info.Size(), region)
 	if err != nil {
 		return nil, err
 	}
 
-	return &RegionReader{
+	rd.Format = regionFileFormat(filename)
+	rd.file = f
+	rd.dir = filepath.Dir(filename)
+	rd.ReadExternal = func(chunk Chunk) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(rd.dir, ExternalChunkFilename(chunk)))
+	}
+
+	return rd, nil
+}
+
+// NewRegionReader returns a region reader which reads the region from rd,
+// such as a file in an archive or a blob in memory. size is the size of the
+// region file. Closing the returned reader does not close rd.
+func NewRegionReader(rd io.ReaderAt, size int64, region Region) (*RegionReader, error) {
+	r := &RegionReader{
 		Region: region,
-		header: header,
-		file:   f,
-	}, nil
+		ReadExternal: func(chunk Chunk) ([]byte, error) {
+			return nil, ErrExternalUnavailable
+		},
+		header: make([]byte, headerSize),
+		src:    rd,
+		size:   size,
+	}
+
+	if err := r.readAt(r.header, 0); err != nil {
+		return nil, err
+	}
+
+	return r, nil
 }
 
+// OpenRegionFileMapped opens a region file like OpenRegionFile, but memory maps
+// the file instead of reading it on demand. The Data of chunks read from the
+// returned reader are slices of the mapping rather than copies, so they must
+// not be modified, and are only valid until the reader is closed. On platforms
+// without mmap support the whole file is read into memory instead, as are
+// .linear region files.
+func OpenRegionFileMapped(filename string) (*RegionReader, error) {
+	rd, err := OpenRegionFile(filename)
+	if err != nil || rd.linear != nil {
+		return rd, err
+	}
+
+	rd.mapped, err = mmapFile(rd.file, int(rd.size))
+	if err != nil {
+		rd.Close()
+		return nil, fmt.Errorf("anvil: failed to map region file: %w", err)
+	}
+
+	return rd, nil
+}
+
+// Close closes the region file. Region readers are safe for concurrent use,
+// but must not be closed while chunks are being read.
 func (r *RegionReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+
+	if r.mapped != nil {
+		if err := munmapFile(r.mapped); err != nil {
+			r.file.Close()
+			return err
+		}
+		r.mapped = nil
+	}
+
 	return r.file.Close()
 }
 
+// Chunks returns the chunks present in the region according to its header.
+func (r *RegionReader) Chunks() []Chunk {
+	var results []Chunk
+
+	for i := 0; i < 4096; i += 4 {
+		if r.sectorOffset(i) != 0 {
+			results = append(results, r.Region.OffsetToChunk(i))
+		}
+	}
+
+	return results
+}
+
+// LastModified returns the time the chunk was last saved from the region's
+// timestamp table, or the zero time if there is no timestamp for it.
+func (r *RegionReader) LastModified(chunk Chunk) time.Time {
+	return r.timestamp(chunk.RegionChunkOffset())
+}
+
+// Timestamps returns the last modified times of all of the chunks present in
+// the region, in the order they appear in the region's header. Only the
+// header is read, so this is very cheap.
+func (r *RegionReader) Timestamps() []ChunkTimestamp {
+	var results []ChunkTimestamp
+
+	for i := 0; i < 4096; i += 4 {
+		if r.sectorOffset(i) == 0 {
+			continue
+		}
+
+		results = append(results, ChunkTimestamp{
+			Chunk:        r.Region.OffsetToChunk(i),
+			LastModified: r.timestamp(i),
+		})
+	}
+
+	return results
+}
+
+// ModifiedSince returns the chunks present in the region which were last
+// saved after the given time, using only the region's header.
+func (r *RegionReader) ModifiedSince(since time.Time) []Chunk {
+	var results []Chunk
+
+	for _, ts := range r.Timestamps() {
+		if ts.LastModified.After(since) {
+			results = append(results, ts.Chunk)
+		}
+	}
+
+	return results
+}
+
+func (r *RegionReader) timestamp(offset int) time.Time {
+	ts := r.header[timestampOffset+offset:]
+	seconds := int64(ts[0])<<24 | int64(ts[1])<<16 | int64(ts[2])<<8 | int64(ts[3])
+	if seconds == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(seconds, 0)
+}
+
+// sectorOffset returns the sector the chunk at the given header offset starts
+// at, or 0 if the chunk is not present.
+func (r *RegionReader) sectorOffset(offset int) int {
+	return int(r.header[offset])<<16 | int(r.header[offset+1])<<8 |
+		int(r.header[offset+2])
+}
+
+// ReadChunk reads the given chunk. Errors are returned as a *ChunkError,
+// wrapping ErrChunkNotPresent if the chunk hasn't been generated.
 func (r *RegionReader) ReadChunk(chunk Chunk) (ChunkData, error) {
 	offset := chunk.RegionChunkOffset()
-	data, err := r.readRawChunk(offset)
+	return r.readChunkData(offset)
+}
+
+func (r *RegionReader) readChunkData(offset int) (ChunkData, error) {
+	chunk := r.Region.OffsetToChunk(offset)
+
+	data, compression, err := r.readRawChunk(offset)
 	if err != nil {
-		return ChunkData{}, err
+		return ChunkData{Chunk: chunk, Format: r.Format}, err
+	}
+
+	c := ChunkData{
+		Chunk:        chunk,
+		Format:       r.Format,
+		Compression:  compression,
+		Data:         data,
+		LastModified: r.timestamp(offset),
 	}
 
-	return ChunkData{
-		Chunk: r.Region.OffsetToChunk(offset),
-		Data:  data,
-	}, nil
+	if compression&externalFlag != 0 {
+		c.Compression &^= externalFlag
+		c.External = true
+		c.Data, err = r.ReadExternal(chunk)
+		if err != nil {
+			return c, newChunkError(chunk,
+				fmt.Errorf("anvil: failed to read external chunk: %w", err))
+		}
+	}
+
+	return c, nil
 }
 
-func (r *RegionReader) readRawChunk(offset int) ([]byte, error) {
-	pos := (int(r.header[offset])<<16 | int(r.header[offset+1])<<8 |
-		int(r.header[offset+2])) << sectorShift
+// ExternalChunkFilename returns the filename of the external .mcc file
+// used to store the given chunk if it is too large to fit in its region file.
+func ExternalChunkFilename(chunk Chunk) string {
+	return fmt.Sprintf("c.%d.%d.mcc", chunk.X, chunk.Z)
+}
 
-	if pos == 0 {
-		return nil, nil
-	}
+// readRawChunk returns the data and compression type byte of the chunk at the
+// given header offset. For memory mapped readers the returned data is a slice
+// of the mapping.
+func (r *RegionReader) readRawChunk(offset int) ([]byte, CompressionType, error) {
+	chunk := r.Region.OffsetToChunk(offset)
 
-	var chunkHeader [5]byte // force a stack allocation
+	if r.sectorOffset(offset) == 0 {
+		return nil, 0, newChunkError(chunk, ErrChunkNotPresent)
+	}
 
-	if _, err := r.file.Seek(int64(pos), 0); err != nil {
-		return nil, err
+	if r.linear != nil {
+		return r.linear[offset>>2], CompressionUncompressed, nil
 	}
 
-	_, err := io.ReadFull(r.file, chunkHeader[:])
+	pos, length, compression, err := r.readChunkHeader(offset)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	length := (int(chunkHeader[0])<<24 | int(chunkHeader[1])<<16 |
-		int(chunkHeader[2])<<8 | int(chunkHeader[3]))
+	end := pos + 5 + length
+
+	if r.mapped != nil {
+		return r.mapped[pos+5 : end : end], compression, nil
+	}
 
 	data := make([]byte, length)
 
-	_, err = io.ReadFull(r.file, data)
-	if err != nil {
-		return nil, err
+	if err := r.readAt(data, pos+5); err != nil {
+		return nil, 0, newChunkError(chunk, err)
 	}
 
-	// fmt.Printf("zlib header: %02x %02x\n", data[0], data[1])
+	return data, compression, nil
+}
 
-	return data, nil
+// readChunkHeader reads the length and compression type byte stored before the
+// data of the present chunk at the given header offset, checking that its
+// data is within the file. pos is the position of the chunk's first sector.
+func (r *RegionReader) readChunkHeader(offset int) (pos, length int64,
+	compression CompressionType, err error) {
+	chunk := r.Region.OffsetToChunk(offset)
+	sector := r.sectorOffset(offset)
+	pos = int64(sector) << sectorShift
+
+	if pos < headerSize || pos+5 > r.size {
+		return 0, 0, 0, newChunkError(chunk, fmt.Errorf("%w: sector %d, region has %d",
+			ErrSectorOutOfRange, sector, (r.size+sectorSize-1)>>sectorShift))
+	}
+
+	var chunkHeader [5]byte // force a stack allocation
+
+	if r.mapped != nil {
+		copy(chunkHeader[:], r.mapped[pos:])
+	} else if err := r.readAt(chunkHeader[:], pos); err != nil {
+		return 0, 0, 0, newChunkError(chunk, err)
+	}
+
+	// the length includes the compression type byte
+	length = (int64(chunkHeader[0])<<24 | int64(chunkHeader[1])<<16 |
+		int64(chunkHeader[2])<<8 | int64(chunkHeader[3])) - 1
+	compression = CompressionType(chunkHeader[4])
+
+	if length < 0 {
+		return 0, 0, 0, newChunkError(chunk, fmt.Errorf("%w: length is 0",
+			ErrLengthMismatch))
+	}
+
+	if end := pos + 5 + length; end > r.size {
+		return 0, 0, 0, newChunkError(chunk, fmt.Errorf(
+			"%w: length %d extends %d bytes past end of file", ErrLengthMismatch,
+			length, end-r.size))
+	}
+
+	return pos, length, compression, nil
+}
+
+// readAt is io.ReadFull for the region's file at the given position.
+func (r *RegionReader) readAt(p []byte, pos int64) error {
+	n, err := r.src.ReadAt(p, pos)
+	if n == len(p) {
+		return nil
+	} else if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
 }
 
 // caller responsibility to close(results)
 func (r *RegionReader) ReadAllChunks(results chan<- ChunkData) error {
-	region := r.Region
-
 	for i := 0; i < 4096; i += 4 {
-		data, err := r.readRawChunk(i)
+		if r.sectorOffset(i) == 0 {
+			continue
+		}
+
+		c, err := r.readChunkData(i)
 		if err != nil {
 			return err
 		}
 
-		if data != nil {
-			c := ChunkData{
-				Chunk: region.OffsetToChunk(i),
-				Data:  data,
-			}
-
-			results <- c
-		}
+		results <- c
 	}
 
 	return nil
 }
 
-func validateFilename(filename string) (region Region, err error) {
+// ParseRegionFilename returns the region of a region file from its filename,
+// which must be of the form r.<x>.<z>.mca, or .mcr or .linear for McRegion and
+// .linear files.
+func ParseRegionFilename(filename string) (region Region, err error) {
 	parts := strings.Split(filepath.Base(filename), ".")
 	if len(parts) != 4 {
 		err = errors.New("must have 4 dot seperated components")
@@ -155,8 +481,8 @@ func validateFilename(filename string) (region Region, err error) {
 		return
 	}
 
-	if parts[3] != "mca" {
-		err = errors.New("extension must be \"mca\"")
+	if parts[3] != "mca" && parts[3] != "mcr" && parts[3] != "linear" {
+		err = errors.New("extension must be \"mca\", \"mcr\" or \"linear\"")
 		return
 	}
 
@@ -0,0 +1,236 @@
+package anvil
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zlib"
+	"github.com/stretchr/testify/assert"
+)
+
+type testChunk struct {
+	chunk       Chunk
+	compression CompressionType
+	data        []byte
+	modified    int64
+}
+
+// writeTestRegion writes a region file with each chunk occupying its own
+// sectors, in order, after the header.
+func writeTestRegion(t *testing.T, filename string, chunks []testChunk) {
+	header := make([]byte, 8192)
+	var body []byte
+
+	for _, c := range chunks {
+		sector := 2 + len(body)>>sectorShift
+		payload := append([]byte{0, 0, 0, 0, byte(c.compression)}, c.data...)
+		length := len(c.data) + 1
+		payload[0], payload[1], payload[2], payload[3] = byte(length>>24),
+			byte(length>>16), byte(length>>8), byte(length)
+		numSectors := (len(payload) + 4095) >> sectorShift
+		payload = append(payload, make([]byte, numSectors<<sectorShift-len(payload))...)
+
+		offset := c.chunk.RegionChunkOffset()
+		header[offset], header[offset+1], header[offset+2], header[offset+3] =
+			byte(sector>>16), byte(sector>>8), byte(sector), byte(numSectors)
+		header[timestampOffset+offset], header[timestampOffset+offset+1],
+			header[timestampOffset+offset+2], header[timestampOffset+offset+3] =
+			byte(c.modified>>24), byte(c.modified>>16), byte(c.modified>>8), byte(c.modified)
+
+		body = append(body, payload...)
+	}
+
+	err := ioutil.WriteFile(filename, append(header, body...), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func zlibCompress(data []byte) []byte {
+	buf := new(bytes.Buffer)
+	wr := zlib.NewWriter(buf)
+	wr.Write(data)
+	wr.Close()
+	return buf.Bytes()
+}
+
+func TestReadRegion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "r.-1.2.mca")
+	writeTestRegion(t, filename, []testChunk{
+		{Chunk{X: -32, Z: 64}, CompressionZlib, zlibCompress([]byte("hello")), 1500000000},
+		{Chunk{X: -1, Z: 95}, CompressionUncompressed, []byte("world"), 1600000000},
+		{Chunk{X: -2, Z: 70}, CompressionZlib | externalFlag, nil, 0},
+	})
+
+	err = ioutil.WriteFile(filepath.Join(dir, "c.-2.70.mcc"),
+		zlibCompress([]byte("external")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := OpenRegionFile(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rd.Close()
+
+	assert.Equal(t, Region{-1, 2}, rd.Region)
+
+	results := make(chan ChunkData, 1024)
+	assert.NoError(t, rd.ReadAllChunks(results))
+	close(results)
+
+	var chunks []ChunkData
+	for c := range results {
+		chunks = append(chunks, c)
+	}
+
+	if !assert.Len(t, chunks, 3) {
+		return
+	}
+
+	expected := map[Chunk]string{
+		{X: -32, Z: 64}: "hello",
+		{X: -1, Z: 95}:  "world",
+		{X: -2, Z: 70}:  "external",
+	}
+
+	for _, c := range chunks {
+		data, err := c.Decompress()
+		assert.NoError(t, err)
+		assert.Equal(t, expected[c.Chunk], string(data))
+		assert.Equal(t, c.Chunk == Chunk{X: -2, Z: 70}, c.External)
+		assert.Equal(t, rd.LastModified(c.Chunk), c.LastModified)
+	}
+
+	assert.Equal(t, time.Unix(1600000000, 0), rd.LastModified(Chunk{X: -1, Z: 95}))
+	assert.True(t, rd.LastModified(Chunk{X: -2, Z: 70}).IsZero())
+	assert.Len(t, rd.Timestamps(), 3)
+	assert.Equal(t, []Chunk{{X: -1, Z: 95}}, rd.ModifiedSince(time.Unix(1550000000, 0)))
+
+	c, err := rd.ReadChunk(Chunk{X: -31, Z: 64})
+	assert.True(t, errors.Is(err, ErrChunkNotPresent))
+	assert.Nil(t, c.Data)
+
+	var chunkErr *ChunkError
+	if assert.True(t, errors.As(err, &chunkErr)) {
+		assert.Equal(t, Region{X: -1, Z: 2}, chunkErr.Region)
+		assert.Equal(t, Chunk{X: -31, Z: 64}, chunkErr.Chunk)
+	}
+}
+
+func TestReadRegionConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var chunks []testChunk
+	for i := 0; i < 64; i++ {
+		chunks = append(chunks, testChunk{
+			chunk:       Chunk{X: i % 32, Z: i / 32},
+			compression: CompressionUncompressed,
+			data:        bytes.Repeat([]byte{byte(i)}, i*100),
+		})
+	}
+
+	filename := filepath.Join(dir, "r.0.0.mca")
+	writeTestRegion(t, filename, chunks)
+
+	for _, open := range []func(string) (*RegionReader, error){
+		OpenRegionFile, OpenRegionFileMapped,
+	} {
+		rd, err := open(filename)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, rd.Chunks(), len(chunks))
+
+		wg := new(sync.WaitGroup)
+		for _, c := range chunks {
+			wg.Add(1)
+			go func(c testChunk) {
+				defer wg.Done()
+
+				data, err := rd.ReadChunk(c.chunk)
+				assert.NoError(t, err)
+				assert.True(t, bytes.Equal(c.data, data.Data), c.chunk)
+			}(c)
+		}
+		wg.Wait()
+
+		assert.NoError(t, rd.Close())
+	}
+}
+
+func TestNewRegionReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "region.bin")
+	writeTestRegion(t, filename, []testChunk{
+		{Chunk{X: 65, Z: 1}, CompressionUncompressed, []byte("blob"), 0},
+		{Chunk{X: 66, Z: 1}, CompressionUncompressed | externalFlag, nil, 0},
+	})
+
+	blob, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := NewRegionReader(bytes.NewReader(blob), int64(len(blob)), Region{X: 2, Z: 0})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c, err := rd.ReadChunk(Chunk{X: 65, Z: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("blob"), c.Data)
+
+	_, err = rd.ReadChunk(Chunk{X: 66, Z: 1})
+	assert.True(t, errors.Is(err, ErrExternalUnavailable))
+
+	rd.ReadExternal = func(chunk Chunk) ([]byte, error) {
+		return []byte(ExternalChunkFilename(chunk)), nil
+	}
+
+	c, err = rd.ReadChunk(Chunk{X: 66, Z: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("c.66.1.mcc"), c.Data)
+	assert.NoError(t, rd.Close())
+
+	_, err = NewRegionReader(bytes.NewReader(blob[:100]), 100, Region{})
+	assert.Error(t, err)
+}
+
+func TestParseRegionFilename(t *testing.T) {
+	region, err := ParseRegionFilename(filepath.Join("world", "region", "r.-3.12.mca"))
+	assert.NoError(t, err)
+	assert.Equal(t, Region{X: -3, Z: 12}, region)
+
+	region, err = ParseRegionFilename("r.0.-1.mcr")
+	assert.NoError(t, err)
+	assert.Equal(t, Region{X: 0, Z: -1}, region)
+
+	for _, name := range []string{"r.1.mca", "c.1.2.mca", "r.1.2.dat", "r.a.2.mca"} {
+		_, err := ParseRegionFilename(name)
+		assert.Error(t, err, name)
+	}
+}
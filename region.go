@@ -19,17 +19,46 @@ type Region struct {
 	Z int
 }
 
+// SectionPos is the position of a 16x16x16 section of a chunk, in units of
+// sections. Y is the section's index from Y 0, which is negative for sections
+// below Y 0.
+type SectionPos struct {
+	X int
+	Y int
+	Z int
+}
+
 func (c *Chunk) RegionChunkOffset() int {
 	return ((c.X & 0b11111) | (c.Z&0b11111)<<5) << 2
 }
 
+// Chunk returns the chunk containing the coordinate, with Y set to the
+// section containing it. Shifts round down, so negative coordinates (including
+// negative Y in 1.18+ worlds) map to the correct chunk and section.
 func (c *Coord) Chunk() Chunk {
 	return Chunk{
 		X: c.X >> 4,
+		Y: c.Y >> 4,
 		Z: c.Z >> 4,
 	}
 }
 
+// Section returns the position of the 16x16x16 section containing the
+// coordinate.
+func (c *Coord) Section() SectionPos {
+	return SectionPos{
+		X: c.X >> 4,
+		Y: c.Y >> 4,
+		Z: c.Z >> 4,
+	}
+}
+
+// SectionOffset returns the position of the coordinate within its section,
+// where each component is from 0 to 15.
+func (c *Coord) SectionOffset() (x, y, z int) {
+	return c.X & 15, c.Y & 15, c.Z & 15
+}
+
 func (c *Coord) Region() Region {
 	return Region{
 		X: c.X >> 9,
@@ -75,3 +104,84 @@ func (r *Region) OffsetToChunk(offset int) Chunk {
 		Z: r.Z<<5 | chunkZ,
 	}
 }
+
+// Chunk returns the chunk the section is in, with Y set to the section's Y.
+func (s *SectionPos) Chunk() Chunk {
+	return Chunk{
+		X: s.X,
+		Y: s.Y,
+		Z: s.Z,
+	}
+}
+
+// CornerCoord returns the coordinate of the section's block with the lowest X,
+// Y and Z.
+func (s *SectionPos) CornerCoord() Coord {
+	return Coord{
+		X: s.X << 4,
+		Y: s.Y << 4,
+		Z: s.Z << 4,
+	}
+}
+
+// Section returns the position of the chunk's section at its Y.
+func (c *Chunk) Section() SectionPos {
+	return SectionPos{
+		X: c.X,
+		Y: c.Y,
+		Z: c.Z,
+	}
+}
+
+// netherScale is the number of overworld blocks per block in the nether.
+const netherScale = 8
+
+// ToNether returns the nether coordinate corresponding to an overworld
+// coordinate, which is where a portal built at the coordinate would link to.
+// Y is unchanged.
+func (c *Coord) ToNether() Coord {
+	return Coord{
+		X: floorDiv(c.X, netherScale),
+		Y: c.Y,
+		Z: floorDiv(c.Z, netherScale),
+	}
+}
+
+// ToOverworld returns the overworld coordinate corresponding to a nether
+// coordinate. Y is unchanged.
+func (c *Coord) ToOverworld() Coord {
+	return Coord{
+		X: c.X * netherScale,
+		Y: c.Y,
+		Z: c.Z * netherScale,
+	}
+}
+
+// ConvertCoord converts a coordinate in one dimension to the corresponding
+// coordinate in another, given the dimensions' names. Only the nether is
+// scaled, every other dimension uses overworld coordinates.
+func ConvertCoord(c Coord, from, to string) Coord {
+	if from == to {
+		return c
+	}
+
+	if from == Nether {
+		c = c.ToOverworld()
+	}
+
+	if to == Nether {
+		c = c.ToNether()
+	}
+
+	return c
+}
+
+// floorDiv divides a by b rounding towards negative infinity, like Minecraft
+// does when converting coordinates.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -18,3 +18,57 @@ func TestCoord(t *testing.T) {
 	assert.Equal(t, 0, r.X)
 	assert.Equal(t, -1, r.Z)
 }
+
+func TestCoordNegativeY(t *testing.T) {
+	c := Coord{-1, -1, -17}
+
+	assert.Equal(t, Chunk{-1, -1, -2}, c.Chunk())
+	assert.Equal(t, SectionPos{-1, -1, -2}, c.Section())
+
+	x, y, z := c.SectionOffset()
+	assert.Equal(t, []int{15, 15, 15}, []int{x, y, z})
+
+	s := SectionPos{2, -4, -3}
+	assert.Equal(t, Coord{32, -64, -48}, s.CornerCoord())
+	chk := s.Chunk()
+	assert.Equal(t, Region{0, -1}, chk.Region())
+}
+
+func TestNetherConversion(t *testing.T) {
+	c := Coord{100, 70, -100}
+
+	assert.Equal(t, Coord{12, 70, -13}, c.ToNether())
+	assert.Equal(t, Coord{-1, 70, 0}, (&Coord{-8, 70, 7}).ToNether())
+	assert.Equal(t, Coord{96, 70, -104}, (&Coord{12, 70, -13}).ToOverworld())
+
+	assert.Equal(t, Coord{12, 70, -13}, ConvertCoord(c, Overworld, Nether))
+	assert.Equal(t, Coord{800, 70, -800}, ConvertCoord(c, Nether, End))
+	assert.Equal(t, c, ConvertCoord(c, End, Overworld))
+}
+
+func TestBox(t *testing.T) {
+	b := NewBox(Coord{20, 80, -1}, Coord{-5, -10, 40})
+	assert.Equal(t, Box{Coord{-5, -10, -1}, Coord{20, 80, 40}}, b)
+
+	assert.True(t, b.Contains(Coord{0, 0, 0}))
+	assert.True(t, b.Contains(Coord{20, 80, 40}))
+	assert.False(t, b.Contains(Coord{0, 81, 0}))
+
+	assert.True(t, b.Intersects(NewBox(Coord{20, 80, 40}, Coord{30, 90, 50})))
+	assert.False(t, b.Intersects(NewBox(Coord{21, 0, 0}, Coord{30, 10, 10})))
+
+	assert.Equal(t, []Chunk{
+		{-1, 0, -1}, {0, 0, -1}, {1, 0, -1},
+		{-1, 0, 0}, {0, 0, 0}, {1, 0, 0},
+		{-1, 0, 1}, {0, 0, 1}, {1, 0, 1},
+		{-1, 0, 2}, {0, 0, 2}, {1, 0, 2},
+	}, b.Chunks())
+	assert.Len(t, b.Sections(), 12*7)
+	assert.Equal(t, []Region{{-1, -1}, {0, -1}, {-1, 0}, {0, 0}}, b.Regions())
+
+	assert.True(t, b.ContainsChunk(Chunk{X: 1, Z: 2}))
+	assert.False(t, b.ContainsChunk(Chunk{X: 2, Z: 2}))
+
+	assert.Equal(t, Box{Coord{-8, 0, 0}, Coord{167, 0, 15}},
+		NewBox(Coord{-1, 0, 0}, Coord{20, 0, 1}).ToOverworld())
+}
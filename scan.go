@@ -0,0 +1,271 @@
+package anvil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChunkHandler is called by a Scanner for every chunk scanned. It is called
+// concurrently from multiple goroutines.
+type ChunkHandler func(c ChunkData) error
+
+// ScanError is an error reading or handling a chunk during a scan. Errors
+// don't stop the scan.
+type ScanError struct {
+	Filename string
+	// Chunk is the chunk the error occurred for, or nil if the region file
+	// couldn't be opened.
+	Chunk *Chunk
+	Err   error
+}
+
+func (e *ScanError) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "anvil: ")
+
+	var chunkErr *ChunkError
+	if e.Chunk == nil || errors.As(e.Err, &chunkErr) {
+		return fmt.Sprintf("anvil: %s: %s", e.Filename, msg)
+	}
+
+	return fmt.Sprintf("anvil: %s: chunk %d, %d: %s", e.Filename, e.Chunk.X,
+		e.Chunk.Z, msg)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// ScanProgress is the progress of a scan.
+type ScanProgress struct {
+	Regions     int
+	RegionsDone int
+	Chunks      int64
+	Errors      int64
+	// BytesRead is the size of the chunk data read from region files, and
+	// BytesDecompressed the size of the chunk data after decompression if
+	// Scanner.Decompress is set.
+	BytesRead         int64
+	BytesDecompressed int64
+	Elapsed           time.Duration
+	// ETA is the estimated time remaining based on the regions done so far,
+	// or 0 if no regions are done.
+	ETA time.Duration
+}
+
+// Scanner reads chunks from many region files in parallel and passes them to
+// a ChunkHandler. The zero value scans every chunk with one worker per CPU.
+type Scanner struct {
+	// Workers is the number of region files to read concurrently, defaulting
+	// to runtime.NumCPU().
+	Workers int
+
+	// Selector selects the regions and chunks to scan, or every chunk if nil.
+	Selector Selector
+
+	// Decompress makes the scanner decompress chunks before passing them to
+	// the handler, so their Compression is always CompressionUncompressed.
+	Decompress bool
+
+	// Progress is called after every region file is done. It is never called
+	// concurrently.
+	Progress func(p ScanProgress)
+}
+
+type scanState struct {
+	// counters are accessed atomically, so they're first for alignment
+	regionsDone int64
+	chunks      int64
+	errors      int64
+	read        int64
+	decompress  int64
+
+	regions int
+	start   time.Time
+
+	mutex      sync.Mutex
+	scanErrors []*ScanError
+}
+
+// ScanDimension scans the region files of the dimension, see Scan. The
+// Dimension and Kind of the chunks passed to handler are set. Use
+// Dimension.Entities and Dimension.POI to scan entity and POI chunks.
+func (s *Scanner) ScanDimension(ctx context.Context, dim *Dimension,
+	handler ChunkHandler) ([]*ScanError, error) {
+	regions, err := dim.Regions()
+	if err != nil {
+		return nil, err
+	}
+
+	filenames := make([]string, len(regions))
+	for i, region := range regions {
+		filenames[i] = dim.RegionFilename(region)
+	}
+
+	return s.Scan(ctx, filenames, func(c ChunkData) error {
+		c.Dimension = dim.Name
+		c.Kind = dim.Kind
+		return handler(c)
+	})
+}
+
+// Scan scans the given region files, calling handler for every chunk. Errors
+// opening regions, reading chunks or returned by handler don't stop the scan,
+// and are returned once the scan is done. Scanning stops early if ctx is
+// cancelled, in which case ctx's error is returned.
+func (s *Scanner) Scan(ctx context.Context, filenames []string,
+	handler ChunkHandler) ([]*ScanError, error) {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if s.Selector != nil {
+		var selected []string
+		for _, filename := range filenames {
+			region, err := ParseRegionFilename(filename)
+			if err != nil || s.Selector.ContainsRegion(region) {
+				selected = append(selected, filename)
+			}
+		}
+		filenames = selected
+	}
+
+	state := &scanState{
+		regions: len(filenames),
+		start:   time.Now(),
+	}
+
+	jobs := make(chan string)
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for filename := range jobs {
+				s.scanRegion(ctx, state, filename, handler)
+
+				atomic.AddInt64(&state.regionsDone, 1)
+				if s.Progress != nil && ctx.Err() == nil {
+					state.mutex.Lock()
+					s.Progress(state.progress())
+					state.mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, filename := range filenames {
+		select {
+		case jobs <- filename:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return state.scanErrors, ctx.Err()
+}
+
+func (s *Scanner) scanRegion(ctx context.Context, state *scanState, filename string,
+	handler ChunkHandler) {
+	rd, err := OpenRegionFile(filename)
+	if err != nil {
+		state.addError(&ScanError{Filename: filename, Err: err})
+		return
+	}
+	defer rd.Close()
+
+	chunks := rd.Chunks()
+	if s.Selector != nil {
+		chunks = rd.SelectChunks(s.Selector)
+	}
+
+	for i := range chunks {
+		if ctx.Err() != nil {
+			return
+		}
+
+		chunk := &chunks[i]
+
+		c, err := rd.ReadChunk(*chunk)
+		if err != nil {
+			state.addError(&ScanError{Filename: filename, Chunk: chunk, Err: err})
+			continue
+		}
+
+		atomic.AddInt64(&state.read, int64(len(c.Data)))
+
+		if s.Decompress {
+			c.Data, err = c.Decompress()
+			if err != nil {
+				state.addError(&ScanError{Filename: filename, Chunk: chunk, Err: err})
+				continue
+			}
+
+			c.Compression = CompressionUncompressed
+			atomic.AddInt64(&state.decompress, int64(len(c.Data)))
+		}
+
+		atomic.AddInt64(&state.chunks, 1)
+
+		if err := handler(c); err != nil {
+			state.addError(&ScanError{Filename: filename, Chunk: chunk, Err: err})
+		}
+	}
+}
+
+func (s *scanState) addError(err *ScanError) {
+	atomic.AddInt64(&s.errors, 1)
+
+	s.mutex.Lock()
+	s.scanErrors = append(s.scanErrors, err)
+	s.mutex.Unlock()
+}
+
+func (s *scanState) progress() ScanProgress {
+	p := ScanProgress{
+		Regions:           s.regions,
+		RegionsDone:       int(atomic.LoadInt64(&s.regionsDone)),
+		Chunks:            atomic.LoadInt64(&s.chunks),
+		Errors:            atomic.LoadInt64(&s.errors),
+		BytesRead:         atomic.LoadInt64(&s.read),
+		BytesDecompressed: atomic.LoadInt64(&s.decompress),
+		Elapsed:           time.Since(s.start),
+	}
+
+	if p.RegionsDone > 0 {
+		p.ETA = p.Elapsed / time.Duration(p.RegionsDone) *
+			time.Duration(p.Regions-p.RegionsDone)
+	}
+
+	return p
+}
+
+// RegionFiles returns the paths to the region files in the given directory,
+// which are McRegion files if it doesn't have any Anvil region files.
+func RegionFiles(dir string) ([]string, error) {
+	dim := &Dimension{RegionDir: dir, Format: regionDirFormat(dir)}
+
+	regions, err := dim.Regions()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]string, len(regions))
+	for i, region := range regions {
+		results[i] = dim.RegionFilename(region)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,96 @@
+package anvil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanner(t *testing.T) {
+	dir := writeTestWorld(t, map[string][]testChunk{
+		"region/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionZlib, zlibCompress([]byte("a")), 0},
+			{Chunk{X: 1, Z: 0}, CompressionZlib, []byte("not zlib"), 0},
+			{Chunk{X: 2, Z: 0}, CompressionZlib, zlibCompress([]byte("fail")), 0},
+		},
+		"region/r.-1.0.mca": {
+			{Chunk{X: -1, Z: 0}, CompressionZlib, zlibCompress([]byte("b")), 0},
+		},
+		"region/r.9.9.mca": {
+			{Chunk{X: 288, Z: 288}, CompressionZlib, zlibCompress([]byte("far")), 0},
+		},
+		"region/r.1.0.mca": nil,
+	})
+	defer os.RemoveAll(dir)
+
+	regionDir := filepath.Join(dir, "region")
+	if err := os.Truncate(filepath.Join(regionDir, "r.1.0.mca"), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := RegionFiles(regionDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 4)
+
+	mutex := new(sync.Mutex)
+	var data []string
+	var progress []ScanProgress
+
+	scanner := &Scanner{
+		Workers:    2,
+		Selector:   BoxSelector{Min: Coord{X: -600}, Max: Coord{X: 600}},
+		Decompress: true,
+		Progress: func(p ScanProgress) {
+			progress = append(progress, p)
+		},
+	}
+
+	scanErrors, err := scanner.Scan(context.Background(), files, func(c ChunkData) error {
+		assert.Equal(t, CompressionUncompressed, c.Compression)
+		if string(c.Data) == "fail" {
+			return os.ErrInvalid
+		}
+
+		mutex.Lock()
+		data = append(data, string(c.Data))
+		mutex.Unlock()
+		return nil
+	})
+	assert.NoError(t, err)
+
+	sort.Strings(data)
+	assert.Equal(t, []string{"a", "b"}, data)
+
+	// the truncated region, the corrupt chunk and the handler error
+	assert.Len(t, scanErrors, 3)
+	for _, err := range scanErrors {
+		if err.Chunk == nil {
+			assert.Equal(t, filepath.Join(regionDir, "r.1.0.mca"), err.Filename)
+		} else if err.Chunk.X == 2 {
+			assert.Equal(t, os.ErrInvalid, err.Err)
+		} else {
+			assert.Equal(t, Chunk{X: 1, Z: 0}, *err.Chunk)
+		}
+	}
+
+	assert.Len(t, progress, 3)
+	last := progress[len(progress)-1]
+	assert.Equal(t, 3, last.Regions)
+	assert.Equal(t, 3, last.RegionsDone)
+	assert.Equal(t, int64(3), last.Chunks)
+	assert.Equal(t, int64(3), last.Errors)
+	assert.Equal(t, int64(6), last.BytesDecompressed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = (&Scanner{}).Scan(ctx, files, func(c ChunkData) error {
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+}
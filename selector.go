@@ -0,0 +1,187 @@
+package anvil
+
+// Selector selects the regions and chunks to read from a dimension or region.
+// Regions are checked first so that region files which can't contain any
+// selected chunks are never opened, then chunks are checked using only the
+// region's location table.
+type Selector interface {
+	// ContainsRegion returns whether any chunk of the region may be selected.
+	ContainsRegion(region Region) bool
+	// ContainsChunk returns whether the chunk is selected. The chunk's Y is
+	// ignored.
+	ContainsChunk(chunk Chunk) bool
+}
+
+// BoxSelector selects the chunks containing any block between Min and Max
+// inclusive, ignoring Y.
+type BoxSelector struct {
+	Min Coord
+	Max Coord
+}
+
+// RadiusSelector selects the chunks containing any block within Radius blocks
+// of Center horizontally.
+type RadiusSelector struct {
+	Center Coord
+	Radius int
+}
+
+// ChunkListSelector selects the given chunks. Use NewChunkListSelector to
+// construct one.
+type ChunkListSelector struct {
+	chunks  map[Chunk]bool
+	regions map[Region]bool
+}
+
+func (s BoxSelector) ContainsRegion(region Region) bool {
+	min, max := s.chunkBounds()
+	corner := region.CornerChunk()
+	return corner.X <= max.X && corner.X+31 >= min.X &&
+		corner.Z <= max.Z && corner.Z+31 >= min.Z
+}
+
+func (s BoxSelector) ContainsChunk(chunk Chunk) bool {
+	min, max := s.chunkBounds()
+	return chunk.X >= min.X && chunk.X <= max.X &&
+		chunk.Z >= min.Z && chunk.Z <= max.Z
+}
+
+// chunkBounds returns the minimum and maximum chunk in the box, allowing for
+// Min and Max being the wrong way round.
+func (s BoxSelector) chunkBounds() (Chunk, Chunk) {
+	min := Chunk{X: minInt(s.Min.X, s.Max.X) >> 4, Z: minInt(s.Min.Z, s.Max.Z) >> 4}
+	max := Chunk{X: maxInt(s.Min.X, s.Max.X) >> 4, Z: maxInt(s.Min.Z, s.Max.Z) >> 4}
+	return min, max
+}
+
+func (s RadiusSelector) ContainsRegion(region Region) bool {
+	return s.containsArea(region.X<<9, region.Z<<9, 512)
+}
+
+func (s RadiusSelector) ContainsChunk(chunk Chunk) bool {
+	return s.containsArea(chunk.X<<4, chunk.Z<<4, 16)
+}
+
+// containsArea returns whether the square of blocks with the given corner and
+// size has any block within the radius.
+func (s RadiusSelector) containsArea(x, z, size int) bool {
+	dx := distanceToRange(s.Center.X, x, x+size-1)
+	dz := distanceToRange(s.Center.Z, z, z+size-1)
+	return dx*dx+dz*dz <= s.Radius*s.Radius
+}
+
+func distanceToRange(v, min, max int) int {
+	if v < min {
+		return min - v
+	} else if v > max {
+		return v - max
+	}
+	return 0
+}
+
+// NewChunkListSelector returns a selector which selects the given chunks.
+func NewChunkListSelector(chunks []Chunk) *ChunkListSelector {
+	s := &ChunkListSelector{
+		chunks:  make(map[Chunk]bool),
+		regions: make(map[Region]bool),
+	}
+
+	for _, chunk := range chunks {
+		s.chunks[Chunk{X: chunk.X, Z: chunk.Z}] = true
+		s.regions[chunk.Region()] = true
+	}
+
+	return s
+}
+
+func (s *ChunkListSelector) ContainsRegion(region Region) bool {
+	return s.regions[region]
+}
+
+func (s *ChunkListSelector) ContainsChunk(chunk Chunk) bool {
+	return s.chunks[Chunk{X: chunk.X, Z: chunk.Z}]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// SelectChunks returns the chunks present in the region which are selected by
+// sel, using only the region's header.
+func (r *RegionReader) SelectChunks(sel Selector) []Chunk {
+	if !sel.ContainsRegion(r.Region) {
+		return nil
+	}
+
+	var results []Chunk
+
+	for _, chunk := range r.Chunks() {
+		if sel.ContainsChunk(chunk) {
+			results = append(results, chunk)
+		}
+	}
+
+	return results
+}
+
+// ReadSelectedChunks reads the chunks selected by sel into results, like
+// ReadAllChunks.
+func (r *RegionReader) ReadSelectedChunks(sel Selector, results chan<- ChunkData) error {
+	for _, chunk := range r.SelectChunks(sel) {
+		c, err := r.ReadChunk(chunk)
+		if err != nil {
+			return err
+		}
+
+		results <- c
+	}
+
+	return nil
+}
+
+// SelectRegions returns the regions of the dimension which have region files
+// and may contain chunks selected by sel, without opening any region files.
+func (d *Dimension) SelectRegions(sel Selector) ([]Region, error) {
+	regions, err := d.Regions()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Region
+
+	for _, region := range regions {
+		if sel.ContainsRegion(region) {
+			results = append(results, region)
+		}
+	}
+
+	return results, nil
+}
+
+// ReadSelectedChunks reads the chunks of the dimension selected by sel into
+// results, with their Dimension set, like ReadAllChunks. Region files which
+// can't contain any selected chunks are not opened.
+func (d *Dimension) ReadSelectedChunks(sel Selector, results chan<- ChunkData) error {
+	regions, err := d.SelectRegions(sel)
+	if err != nil {
+		return err
+	}
+
+	for _, region := range regions {
+		if err := d.readRegion(region, sel, results); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package anvil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectors(t *testing.T) {
+	box := BoxSelector{
+		Min: Coord{X: 100, Z: 20},
+		Max: Coord{X: -20, Z: -600},
+	}
+
+	assert.True(t, box.ContainsRegion(Region{X: -1, Z: -2}))
+	assert.True(t, box.ContainsRegion(Region{X: 0, Z: 0}))
+	assert.False(t, box.ContainsRegion(Region{X: 1, Z: 0}))
+	assert.False(t, box.ContainsRegion(Region{X: 0, Z: -3}))
+	assert.True(t, box.ContainsChunk(Chunk{X: -2, Z: -38}))
+	assert.True(t, box.ContainsChunk(Chunk{X: 6, Z: 1}))
+	assert.False(t, box.ContainsChunk(Chunk{X: -3, Z: 0}))
+	assert.False(t, box.ContainsChunk(Chunk{X: 7, Z: 0}))
+
+	radius := RadiusSelector{Center: Coord{X: 8, Y: 64, Z: 8}, Radius: 500}
+
+	assert.True(t, radius.ContainsRegion(Region{X: -1, Z: -1}))
+	assert.True(t, radius.ContainsRegion(Region{X: 0, Z: -1}))
+	assert.False(t, radius.ContainsRegion(Region{X: 1, Z: 0}))
+	assert.False(t, radius.ContainsRegion(Region{X: 1, Z: 1}))
+	assert.False(t, radius.ContainsRegion(Region{X: 2, Z: 0}))
+	assert.True(t, radius.ContainsChunk(Chunk{X: 31, Z: 0}))
+	assert.False(t, radius.ContainsChunk(Chunk{X: 32, Z: 0}))
+	assert.False(t, radius.ContainsChunk(Chunk{X: 23, Z: 23}))
+
+	list := NewChunkListSelector([]Chunk{{X: 1, Y: 4, Z: 1}, {X: -40, Z: 2}})
+
+	assert.True(t, list.ContainsRegion(Region{X: 0, Z: 0}))
+	assert.True(t, list.ContainsRegion(Region{X: -2, Z: 0}))
+	assert.False(t, list.ContainsRegion(Region{X: -1, Z: 0}))
+	assert.True(t, list.ContainsChunk(Chunk{X: 1, Z: 1}))
+	assert.False(t, list.ContainsChunk(Chunk{X: 1, Z: 2}))
+}
+
+func TestReadSelectedChunks(t *testing.T) {
+	dir := writeTestWorld(t, map[string][]testChunk{
+		"region/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("a"), 0},
+			{Chunk{X: 20, Z: 0}, CompressionUncompressed, []byte("b"), 0},
+		},
+		"region/r.-1.0.mca": {
+			{Chunk{X: -1, Z: 0}, CompressionUncompressed, []byte("c"), 0},
+		},
+		// not a valid region file, so opening it would fail
+		"region/r.5.5.mca": nil,
+	}, "level.dat")
+	defer os.RemoveAll(dir)
+
+	if err := os.Truncate(dir+"/region/r.5.5.mca", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenWorld(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	dim := w.Dimension(Overworld)
+
+	regions, err := dim.SelectRegions(RadiusSelector{Radius: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, []Region{{X: -1, Z: 0}, {X: 0, Z: 0}}, regions)
+
+	results := make(chan ChunkData, 10)
+	assert.NoError(t, dim.ReadSelectedChunks(RadiusSelector{Radius: 100}, results))
+	close(results)
+
+	var data []string
+	for c := range results {
+		assert.Equal(t, Overworld, c.Dimension)
+		data = append(data, string(c.Data))
+	}
+
+	assert.Equal(t, []string{"c", "a"}, data)
+}
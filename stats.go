@@ -0,0 +1,244 @@
+package anvil
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// ChunkStat is the size of a chunk in its region file.
+type ChunkStat struct {
+	Chunk Chunk `json:"chunk"`
+	// Length is the length of the chunk's compressed data, which for external
+	// chunks is stored in their .mcc file and not counted.
+	Length   int64 `json:"length"`
+	Sectors  int   `json:"sectors"`
+	External bool  `json:"external,omitempty"`
+}
+
+// StatTotals are the totals of region statistics, for a single region or a
+// whole dimension.
+type StatTotals struct {
+	// Size is the total size of the region files, and Chunks the number of
+	// chunks present in them.
+	Size     int64 `json:"size"`
+	Chunks   int   `json:"chunks"`
+	External int   `json:"external"`
+
+	// CompressedBytes is the total length of the chunks' compressed data.
+	CompressedBytes int64 `json:"compressed_bytes"`
+
+	// FreeSectors is the number of sectors after the header not used by any
+	// chunk, which are left behind when chunks grow and are moved. Waste is
+	// the number of bytes of the region files used by neither the header nor
+	// chunk data, including free sectors and the unused ends of chunks' last
+	// sectors.
+	FreeSectors int   `json:"free_sectors"`
+	Waste       int64 `json:"waste"`
+
+	// Errors is the number of chunks whose location or length is invalid,
+	// which aren't included in the other totals.
+	Errors int `json:"errors"`
+
+	// Largest are the largest chunks by compressed length, largest first.
+	Largest []ChunkStat `json:"largest"`
+
+	// Oldest and Newest are the earliest and latest chunk timestamps, ignoring
+	// chunks without one.
+	Oldest time.Time `json:"oldest"`
+	Newest time.Time `json:"newest"`
+}
+
+// RegionStats are the statistics of a single region file.
+type RegionStats struct {
+	Filename string `json:"filename"`
+	Region   Region `json:"region"`
+	StatTotals
+}
+
+// DimensionStats are the statistics of all of the region files of a
+// dimension's region, entities or poi folder, which is given by Kind.
+type DimensionStats struct {
+	Name    string         `json:"name"`
+	Kind    string         `json:"kind"`
+	Regions []*RegionStats `json:"regions"`
+	StatTotals
+
+	// Failed are the region files whose statistics couldn't be computed,
+	// such as empty or truncated files, which aren't included in the totals.
+	Failed []RegionFailure `json:"failed,omitempty"`
+}
+
+// RegionFailure is a region file whose statistics couldn't be computed.
+type RegionFailure struct {
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+}
+
+// StatRegion computes the statistics of a region file using only its header
+// and the length of each chunk, without reading any chunk data. largest is the
+// number of largest chunks to keep. .linear regions aren't supported.
+func StatRegion(filename string, largest int) (*RegionStats, error) {
+	rd, err := OpenRegionFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+
+	if rd.linear != nil {
+		return nil, ErrLinearRegion
+	}
+
+	stats := &RegionStats{
+		Filename: filename,
+		Region:   rd.Region,
+		StatTotals: StatTotals{
+			Size:  rd.size,
+			Waste: rd.size - headerSize,
+		},
+	}
+
+	used := make([]bool, (rd.size+sectorSize-1)>>sectorShift)
+
+	for i := 0; i < 4096; i += 4 {
+		if rd.sectorOffset(i) == 0 {
+			continue
+		}
+
+		_, length, compression, err := rd.readChunkHeader(i)
+		if err != nil {
+			stats.Errors++
+			continue
+		}
+
+		start, count := rd.sectorOffset(i), rd.sectorCount(i)
+		for s := start; s < start+count && s < len(used); s++ {
+			used[s] = true
+		}
+
+		chunk := ChunkStat{
+			Chunk:    rd.Region.OffsetToChunk(i),
+			Length:   length,
+			Sectors:  count,
+			External: compression&externalFlag != 0,
+		}
+
+		stats.Chunks++
+		stats.CompressedBytes += length
+		stats.Waste -= 5 + length
+		if chunk.External {
+			stats.External++
+		}
+
+		stats.addLargest([]ChunkStat{chunk}, largest)
+		stats.addTimestamp(rd.timestamp(i))
+	}
+
+	for s := headerSize >> sectorShift; s < len(used); s++ {
+		if !used[s] {
+			stats.FreeSectors++
+		}
+	}
+
+	return stats, nil
+}
+
+// Stats computes the statistics of every region file of the dimension, see
+// StatRegion. .linear region files are skipped, and region files which can't
+// be read are recorded in Failed. An error is only returned if the region
+// folder can't be listed.
+func (d *Dimension) Stats(largest int) (*DimensionStats, error) {
+	regions, err := d.Regions()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &DimensionStats{
+		Name: d.Name,
+		Kind: d.Kind.String(),
+	}
+
+	for _, region := range regions {
+		filename := d.RegionFilename(region)
+
+		rs, err := StatRegion(filename, largest)
+		if os.IsNotExist(err) || err == ErrLinearRegion {
+			continue
+		} else if err != nil {
+			stats.Failed = append(stats.Failed, RegionFailure{
+				Filename: filename,
+				Error:    err.Error(),
+			})
+			continue
+		}
+
+		stats.Regions = append(stats.Regions, rs)
+		stats.add(&rs.StatTotals, largest)
+	}
+
+	return stats, nil
+}
+
+// Stats computes the statistics of every dimension of the world, see
+// Dimension.Stats. The region, entities and poi folders of each dimension
+// have separate statistics, in that order.
+func (w *World) Stats(largest int) ([]*DimensionStats, error) {
+	var results []*DimensionStats
+
+	for _, dim := range w.Dimensions {
+		for _, view := range dim.Views() {
+			stats, err := view.Stats(largest)
+			if err != nil {
+				return nil, err
+			}
+
+			results = append(results, stats)
+		}
+	}
+
+	return results, nil
+}
+
+// add adds the totals of o to t, keeping the largest chunks of both.
+func (t *StatTotals) add(o *StatTotals, largest int) {
+	t.Size += o.Size
+	t.Chunks += o.Chunks
+	t.External += o.External
+	t.CompressedBytes += o.CompressedBytes
+	t.FreeSectors += o.FreeSectors
+	t.Waste += o.Waste
+	t.Errors += o.Errors
+
+	t.addLargest(o.Largest, largest)
+	t.addTimestamp(o.Oldest)
+	t.addTimestamp(o.Newest)
+}
+
+func (t *StatTotals) addLargest(chunks []ChunkStat, largest int) {
+	if largest <= 0 {
+		return
+	}
+
+	t.Largest = append(t.Largest, chunks...)
+	sort.SliceStable(t.Largest, func(i, j int) bool {
+		return t.Largest[i].Length > t.Largest[j].Length
+	})
+
+	if len(t.Largest) > largest {
+		t.Largest = t.Largest[:largest]
+	}
+}
+
+func (t *StatTotals) addTimestamp(ts time.Time) {
+	if ts.IsZero() {
+		return
+	}
+
+	if t.Oldest.IsZero() || ts.Before(t.Oldest) {
+		t.Oldest = ts
+	}
+
+	if ts.After(t.Newest) {
+		t.Newest = ts
+	}
+}
@@ -0,0 +1,81 @@
+package anvil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	dir := writeTestWorld(t, map[string][]testChunk{
+		"region/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("small"), 100},
+			{Chunk{X: 1, Z: 0}, CompressionUncompressed, bytes.Repeat([]byte{1}, 5000), 300},
+			{Chunk{X: 2, Z: 0}, CompressionUncompressed, []byte("medium chunk"), 0},
+		},
+		"region/r.-1.0.mca": {
+			{Chunk{X: -1, Z: 0}, CompressionUncompressed, bytes.Repeat([]byte{2}, 100), 200},
+		},
+		"entities/r.0.0.mca": {
+			{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("entities"), 100},
+		},
+	}, "level.dat", "region/r.1.0.mca")
+	defer os.RemoveAll(dir)
+
+	// free the medium chunk's sector
+	w, err := OpenRegionWriter(filepath.Join(dir, "region", "r.0.0.mca"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.DeleteChunk(Chunk{X: 2, Z: 0}))
+	w.Close()
+
+	rs, err := StatRegion(filepath.Join(dir, "region", "r.0.0.mca"), 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, int64(6*sectorSize), rs.Size)
+	assert.Equal(t, 2, rs.Chunks)
+	assert.Equal(t, int64(5005), rs.CompressedBytes)
+	assert.Equal(t, 1, rs.FreeSectors)
+	assert.Equal(t, int64(4*sectorSize-10-5005), rs.Waste)
+	assert.Equal(t, []ChunkStat{{Chunk: Chunk{X: 1, Z: 0}, Length: 5000, Sectors: 2}},
+		rs.Largest)
+	assert.Equal(t, time.Unix(100, 0), rs.Oldest)
+	assert.Equal(t, time.Unix(300, 0), rs.Newest)
+
+	world, err := OpenWorld(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the region file that isn't a valid region file doesn't stop the others
+	// from being read
+	stats, err := world.Stats(2)
+	if !assert.NoError(t, err) || !assert.Len(t, stats, 2) {
+		return
+	}
+
+	dim := stats[0]
+	assert.Equal(t, Overworld, dim.Name)
+	assert.Equal(t, "region", dim.Kind)
+	assert.Len(t, dim.Regions, 2)
+	if assert.Len(t, dim.Failed, 1) {
+		assert.Equal(t, filepath.Join(dir, "region", "r.1.0.mca"), dim.Failed[0].Filename)
+	}
+	assert.Equal(t, 3, dim.Chunks)
+	assert.Equal(t, int64(5105), dim.CompressedBytes)
+	assert.Equal(t, []Chunk{{X: 1, Z: 0}, {X: -1, Z: 0}},
+		[]Chunk{dim.Largest[0].Chunk, dim.Largest[1].Chunk})
+	assert.Equal(t, time.Unix(100, 0), dim.Oldest)
+	assert.Equal(t, time.Unix(300, 0), dim.Newest)
+
+	assert.Equal(t, "entities", stats[1].Kind)
+	assert.Equal(t, 1, stats[1].Chunks)
+	assert.Empty(t, stats[1].Failed)
+}
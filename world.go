@@ -0,0 +1,336 @@
+package anvil
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names of the vanilla dimensions.
+const (
+	Overworld = "minecraft:overworld"
+	Nether    = "minecraft:the_nether"
+	End       = "minecraft:the_end"
+)
+
+var ErrNotWorld = errors.New("anvil: not a world directory")
+
+// World is a Minecraft world directory.
+type World struct {
+	Dir string
+
+	// Dimensions are the dimensions found in the world, with the vanilla
+	// dimensions first, followed by mod dimensions sorted by name.
+	Dimensions []*Dimension
+
+	// LevelDat, PlayerDataDir and DataDir are the paths to level.dat, the
+	// playerdata folder and the data folder, or empty if they don't exist.
+	LevelDat      string
+	PlayerDataDir string
+	DataDir       string
+}
+
+// Dimension is a dimension of a world with its own region folder.
+type Dimension struct {
+	// Name is the namespaced name of the dimension, such as
+	// minecraft:the_nether, or DIM<id> for legacy mod dimensions.
+	Name string
+
+	// Dir is the directory of the dimension, which contains its region
+	// folder.
+	Dir string
+
+	// RegionDir is the dimension's region folder.
+	RegionDir string
+
+	// Format is the format of the dimension's region files. Worlds which have
+	// been converted to Anvil still contain their old .mcr files, which are
+	// ignored.
+	Format RegionFormat
+
+	// Kind is the kind of chunks in RegionDir, RegionTerrain for dimensions
+	// of a world.
+	Kind RegionKind
+
+	// EntitiesDir and POIDir are the dimension's entities and poi region
+	// folders, or empty if they don't exist. They're only present in worlds
+	// saved by Minecraft 1.14 (poi) and 1.17 (entities) or later.
+	EntitiesDir string
+	POIDir      string
+}
+
+// OpenWorld discovers the dimensions and data files of the world in the given
+// directory. Vanilla dimensions are found in region/, DIM-1/region and
+// DIM1/region, mod dimensions in DIM<id>/region (Forge before 1.16) and
+// dimensions/<namespace>/<name>/region.
+func OpenWorld(dir string) (*World, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s is not a directory", ErrNotWorld, dir)
+	}
+
+	w := &World{
+		Dir:           dir,
+		LevelDat:      existingPath(filepath.Join(dir, "level.dat"), false),
+		PlayerDataDir: existingPath(filepath.Join(dir, "playerdata"), true),
+		DataDir:       existingPath(filepath.Join(dir, "data"), true),
+	}
+
+	w.addDimension(Overworld, dir)
+	w.addDimension(Nether, filepath.Join(dir, "DIM-1"))
+	w.addDimension(End, filepath.Join(dir, "DIM1"))
+
+	var mods []*Dimension
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if !file.IsDir() || !strings.HasPrefix(name, "DIM") || name == "DIM-1" ||
+			name == "DIM1" {
+			continue
+		}
+
+		if _, err := strconv.Atoi(name[3:]); err != nil {
+			continue
+		}
+
+		if dim := newDimension(name, filepath.Join(dir, name)); dim != nil {
+			mods = append(mods, dim)
+		}
+	}
+
+	dimensionsDir := filepath.Join(dir, "dimensions")
+	filepath.Walk(dimensionsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || info.Name() != "region" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dimensionsDir, filepath.Dir(path))
+		if err != nil {
+			return nil
+		}
+
+		parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+		if len(parts) != 2 {
+			return filepath.SkipDir
+		}
+
+		name := parts[0] + ":" + parts[1]
+		if w.Dimension(name) == nil {
+			mods = append(mods, newDimension(name, filepath.Dir(path)))
+		}
+
+		return filepath.SkipDir
+	})
+
+	sort.Slice(mods, func(i, j int) bool {
+		return mods[i].Name < mods[j].Name
+	})
+
+	w.Dimensions = append(w.Dimensions, mods...)
+
+	if w.LevelDat == "" && len(w.Dimensions) == 0 {
+		return nil, fmt.Errorf("%w: no level.dat or region folders in %s", ErrNotWorld, dir)
+	}
+
+	return w, nil
+}
+
+func existingPath(path string, dir bool) string {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() != dir {
+		return ""
+	}
+
+	return path
+}
+
+func newDimension(name, dir string) *Dimension {
+	regionDir := existingPath(filepath.Join(dir, "region"), true)
+	if regionDir == "" {
+		return nil
+	}
+
+	return &Dimension{
+		Name:        name,
+		Dir:         dir,
+		RegionDir:   regionDir,
+		Format:      regionDirFormat(regionDir),
+		EntitiesDir: existingPath(filepath.Join(dir, "entities"), true),
+		POIDir:      existingPath(filepath.Join(dir, "poi"), true),
+	}
+}
+
+func (w *World) addDimension(name, dir string) {
+	if dim := newDimension(name, dir); dim != nil {
+		w.Dimensions = append(w.Dimensions, dim)
+	}
+}
+
+// Dimension returns the dimension with the given name, or nil if the world
+// doesn't have it.
+func (w *World) Dimension(name string) *Dimension {
+	for _, dim := range w.Dimensions {
+		if dim.Name == name {
+			return dim
+		}
+	}
+
+	return nil
+}
+
+// PlayerFiles returns the paths to the player data files of the world.
+func (w *World) PlayerFiles() ([]string, error) {
+	if w.PlayerDataDir == "" {
+		return nil, nil
+	}
+
+	files, err := ioutil.ReadDir(w.PlayerDataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []string
+
+	for _, file := range files {
+		if filepath.Ext(file.Name()) == ".dat" {
+			results = append(results, filepath.Join(w.PlayerDataDir, file.Name()))
+		}
+	}
+
+	return results, nil
+}
+
+// ReadAllChunks reads all of the chunks of all dimensions into results,
+// including their entity and POI chunks, which are told apart by their Kind.
+// See Dimension.ReadAllChunks.
+func (w *World) ReadAllChunks(results chan<- ChunkData) error {
+	for _, dim := range w.Dimensions {
+		for _, view := range dim.Views() {
+			if err := view.ReadAllChunks(results); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Regions returns the regions of the dimension which have region files of the
+// dimension's format, sorted by their filename.
+func (d *Dimension) Regions() ([]Region, error) {
+	files, err := ioutil.ReadDir(d.RegionDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Region
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != d.Format.Extension() {
+			continue
+		}
+
+		region, err := ParseRegionFilename(file.Name())
+		if err != nil {
+			continue
+		}
+
+		results = append(results, region)
+	}
+
+	return results, nil
+}
+
+// RegionFilename returns the path to the region file of the given region,
+// which may not exist.
+func (d *Dimension) RegionFilename(region Region) string {
+	return filepath.Join(d.RegionDir, fmt.Sprintf("r.%d.%d%s", region.X, region.Z,
+		d.Format.Extension()))
+}
+
+// OpenRegion opens the region file of the given region.
+func (d *Dimension) OpenRegion(region Region) (*RegionReader, error) {
+	return OpenRegionFile(d.RegionFilename(region))
+}
+
+// ReadChunk reads the given chunk. If the chunk or its region doesn't exist,
+// a *ChunkError wrapping ErrChunkNotPresent is returned.
+func (d *Dimension) ReadChunk(chunk Chunk) (ChunkData, error) {
+	rd, err := d.OpenRegion(chunk.Region())
+	if os.IsNotExist(err) {
+		return ChunkData{Chunk: chunk, Dimension: d.Name, Kind: d.Kind},
+			newChunkError(chunk, ErrChunkNotPresent)
+	} else if err != nil {
+		return ChunkData{}, err
+	}
+	defer rd.Close()
+
+	c, err := rd.ReadChunk(chunk)
+	c.Dimension = d.Name
+	c.Kind = d.Kind
+	return c, err
+}
+
+// ReadChunkAt reads the chunk containing the given block coordinate, see
+// ReadChunk.
+func (d *Dimension) ReadChunkAt(coord Coord) (ChunkData, error) {
+	return d.ReadChunk(coord.Chunk())
+}
+
+// ReadAllChunks reads all of the chunks in every region of the dimension into
+// results, with their Dimension and Kind set. Like RegionReader.ReadAllChunks it stops
+// at the first error, and it is the caller's responsibility to close results.
+func (d *Dimension) ReadAllChunks(results chan<- ChunkData) error {
+	regions, err := d.Regions()
+	if err != nil {
+		return err
+	}
+
+	for _, region := range regions {
+		if err := d.readRegion(region, nil, results); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readRegion reads the chunks of the region selected by sel into results, or
+// all of them if sel is nil.
+func (d *Dimension) readRegion(region Region, sel Selector, results chan<- ChunkData) error {
+	rd, err := d.OpenRegion(region)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	chunks := rd.Chunks()
+	if sel != nil {
+		chunks = rd.SelectChunks(sel)
+	}
+
+	for _, chunk := range chunks {
+		c, err := rd.ReadChunk(chunk)
+		if err != nil {
+			return err
+		}
+
+		c.Dimension = d.Name
+		c.Kind = d.Kind
+		results <- c
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package anvil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestWorld creates a world with the given region files, relative to the
+// world directory, each containing the given chunks.
+func writeTestWorld(t *testing.T, regions map[string][]testChunk, files ...string) string {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, chunks := range regions {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestRegion(t, filename, chunks)
+	}
+
+	for _, name := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestOpenWorld(t *testing.T) {
+	overworld := []testChunk{
+		{Chunk{X: -1, Z: -1}, CompressionUncompressed, []byte("overworld"), 0},
+		{Chunk{X: -2, Z: -1}, CompressionUncompressed, []byte("overworld 2"), 0},
+	}
+	other := []testChunk{
+		{Chunk{X: 0, Z: 0}, CompressionUncompressed, []byte("other"), 0},
+	}
+
+	dir := writeTestWorld(t, map[string][]testChunk{
+		"region/r.-1.-1.mca":                            overworld,
+		"DIM-1/region/r.0.0.mca":                        other,
+		"DIM7/region/r.0.0.mca":                         other,
+		"dimensions/mymod/mining/deep/region/r.0.0.mca": other,
+	}, "level.dat", "playerdata/abc.dat", "playerdata/abc.dat_old", "DIM1/data/raids.dat")
+	defer os.RemoveAll(dir)
+
+	w, err := OpenWorld(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var names []string
+	for _, dim := range w.Dimensions {
+		names = append(names, dim.Name)
+	}
+
+	assert.Equal(t, []string{Overworld, Nether, "DIM7", "mymod:mining/deep"}, names)
+	assert.Equal(t, filepath.Join(dir, "level.dat"), w.LevelDat)
+	assert.Equal(t, "", w.DataDir)
+	assert.Nil(t, w.Dimension(End))
+
+	players, err := w.PlayerFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "playerdata", "abc.dat")}, players)
+
+	c, err := w.Dimension(Overworld).ReadChunkAt(Coord{X: -10, Y: 64, Z: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("overworld"), c.Data)
+	assert.Equal(t, Overworld, c.Dimension)
+
+	c, err = w.Dimension(Nether).ReadChunkAt(Coord{X: 1000, Y: 64, Z: 1000})
+	assert.True(t, errors.Is(err, ErrChunkNotPresent))
+	assert.Nil(t, c.Data)
+
+	results := make(chan ChunkData, 100)
+	assert.NoError(t, w.ReadAllChunks(results))
+	close(results)
+
+	counts := make(map[string]int)
+	for c := range results {
+		counts[c.Dimension]++
+	}
+
+	assert.Equal(t, map[string]int{
+		Overworld:           2,
+		Nether:              1,
+		"DIM7":              1,
+		"mymod:mining/deep": 1,
+	}, counts)
+
+	_, err = OpenWorld(filepath.Join(dir, "playerdata"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,293 @@
+package anvil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	sectorSize = 1 << sectorShift
+
+	// maxChunkSectors is the largest number of sectors that can be recorded
+	// for a chunk in the location table. Larger chunks are stored externally.
+	maxChunkSectors = 255
+)
+
+var ErrChunkOutOfRegion = errors.New("anvil: chunk is not in region")
+
+// RegionWriter inserts, replaces and deletes chunks in a region file. It
+// embeds a RegionReader over the same file, so chunks written can be read
+// back immediately.
+type RegionWriter struct {
+	*RegionReader
+
+	// Compression is the compression type used by WriteChunk. It defaults
+	// to zlib, which is what vanilla uses.
+	Compression CompressionType
+
+	// used is the free space map of the region file, where used[i] is
+	// true if sector i is occupied by the header or a chunk.
+	used []bool
+
+	// atomic is set for writers opened with OpenRegionWriterAtomic.
+	atomic *atomicWrite
+}
+
+// OpenRegionWriter opens the region file for reading and writing, creating
+// it if it does not exist.
+func OpenRegionWriter(filename string) (*RegionWriter, error) {
+	region, err := ParseRegionFilename(filename)
+	if err != nil {
+		return nil, fmt.Errorf("anvil: not a valid region filename: %w", err)
+	} else if regionFileFormat(filename) == FormatLinear {
+		return nil, ErrLinearRegion
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := newRegionWriter(f, region, filename)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func newRegionWriter(f *os.File, region Region, filename string) (*RegionWriter, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() < headerSize {
+		// new or truncated region, pad out the header
+		if err := f.Truncate(headerSize); err != nil {
+			return nil, err
+		}
+	}
+
+	rd, err := newRegionReader(f, region, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &RegionWriter{
+		RegionReader: rd,
+		Compression:  CompressionZlib,
+	}
+	rd.ReadExternal = w.readExternal
+
+	size := info.Size()
+	if size < headerSize {
+		size = headerSize
+	}
+
+	w.used = make([]bool, (size+sectorSize-1)>>sectorShift)
+	w.used[0], w.used[1] = true, true
+
+	for i := 0; i < 4096; i += 4 {
+		start, count := w.sectorOffset(i), w.sectorCount(i)
+		if start == 0 {
+			continue
+		}
+
+		w.markSectors(start, count, true)
+	}
+
+	return w, nil
+}
+
+// sectorCount returns the number of sectors occupied by the chunk at the given
+// header offset.
+func (r *RegionReader) sectorCount(offset int) int {
+	return int(r.header[offset+3])
+}
+
+func (w *RegionWriter) markSectors(start, count int, used bool) {
+	for i := start; i < start+count; i++ {
+		if i >= len(w.used) {
+			if !used {
+				return
+			}
+			w.used = append(w.used, make([]bool, i+1-len(w.used))...)
+		}
+		w.used[i] = used
+	}
+}
+
+// allocate finds the first run of count free sectors, growing the file if
+// there isn't one. The sectors are not marked as used.
+func (w *RegionWriter) allocate(count int) int {
+	run := 0
+	for i, used := range w.used {
+		if used {
+			run = 0
+			continue
+		}
+
+		run++
+		if run == count {
+			return i - count + 1
+		}
+	}
+
+	return len(w.used) - run
+}
+
+// WriteChunk compresses the uncompressed NBT data using the writer's
+// compression type and writes it as the given chunk, replacing any existing
+// chunk. The chunk's timestamp is set to the current time.
+func (w *RegionWriter) WriteChunk(chunk Chunk, data []byte) error {
+	compressed, err := w.Compression.Compress(data)
+	if err != nil {
+		return err
+	}
+
+	return w.WriteRawChunk(ChunkData{
+		Chunk:        chunk,
+		Compression:  w.Compression,
+		Data:         compressed,
+		LastModified: time.Now(),
+	})
+}
+
+// WriteRawChunk writes the already compressed chunk data, replacing any
+// existing chunk. The chunk's timestamp is set to c.LastModified, which is
+// kept as no timestamp if it is zero, so chunks can be copied between regions
+// unchanged. Chunks larger than 1 MiB are stored in an external .mcc file like
+// vanilla does.
+func (w *RegionWriter) WriteRawChunk(c ChunkData) error {
+	if c.Chunk.Region() != w.Region {
+		return fmt.Errorf("%w: %v", ErrChunkOutOfRegion, c.Chunk)
+	}
+
+	if !c.Compression.Valid() {
+		return fmt.Errorf("%w: %v", ErrUnknownCompression, c.Compression)
+	}
+
+	// the length field includes the compression type byte
+	payload := make([]byte, 5, 5+len(c.Data))
+	payload = append(payload, c.Data...)
+
+	external := (len(payload)+sectorSize-1)>>sectorShift > maxChunkSectors
+	externalPath := filepath.Join(w.dir, ExternalChunkFilename(c.Chunk))
+	compression := byte(c.Compression)
+
+	if external {
+		if err := w.writeExternal(externalPath, c.Data); err != nil {
+			return err
+		}
+
+		payload = payload[:5]
+		compression |= externalFlag
+	}
+
+	length := len(payload) - 4
+	payload[0], payload[1], payload[2], payload[3], payload[4] = byte(length>>24),
+		byte(length>>16), byte(length>>8), byte(length), compression
+
+	numSectors := (len(payload) + sectorSize - 1) >> sectorShift
+	payload = append(payload, make([]byte, numSectors<<sectorShift-len(payload))...)
+
+	offset := c.Chunk.RegionChunkOffset()
+	oldStart, oldCount := w.sectorOffset(offset), w.sectorCount(offset)
+
+	// the old sectors are only freed once the header points to the new ones,
+	// so a failed write never leaves the header pointing to a partial chunk
+	start := w.allocate(numSectors)
+	if _, err := w.file.WriteAt(payload, int64(start)<<sectorShift); err != nil {
+		return err
+	}
+	w.markSectors(start, numSectors, true)
+
+	if end := int64(start+numSectors) << sectorShift; end > w.size {
+		w.size = end
+	}
+
+	if err := w.writeHeader(offset, start, numSectors, c.LastModified); err != nil {
+		return err
+	}
+	w.markSectors(oldStart, oldCount, false)
+
+	if !external {
+		return w.writeExternal(externalPath, nil)
+	}
+
+	return nil
+}
+
+// DeleteChunk removes the chunk from the region, freeing its sectors. It is
+// not an error to delete a chunk that does not exist.
+func (w *RegionWriter) DeleteChunk(chunk Chunk) error {
+	if chunk.Region() != w.Region {
+		return fmt.Errorf("%w: %v", ErrChunkOutOfRegion, chunk)
+	}
+
+	offset := chunk.RegionChunkOffset()
+	if w.sectorOffset(offset) == 0 {
+		return nil
+	}
+
+	w.markSectors(w.sectorOffset(offset), w.sectorCount(offset), false)
+
+	if err := w.writeHeader(offset, 0, 0, time.Time{}); err != nil {
+		return err
+	}
+
+	return w.writeExternal(filepath.Join(w.dir, ExternalChunkFilename(chunk)), nil)
+}
+
+// writeHeader updates the location and timestamp table entries of the chunk
+// at the given header offset, both in memory and on disk.
+func (w *RegionWriter) writeHeader(offset, start, numSectors int, modified time.Time) error {
+	var seconds int64
+	if !modified.IsZero() {
+		seconds = modified.Unix()
+	}
+
+	loc := w.header[offset : offset+4]
+	loc[0], loc[1], loc[2], loc[3] = byte(start>>16), byte(start>>8), byte(start),
+		byte(numSectors)
+
+	ts := w.header[timestampOffset+offset : timestampOffset+offset+4]
+	ts[0], ts[1], ts[2], ts[3] = byte(seconds>>24), byte(seconds>>16),
+		byte(seconds>>8), byte(seconds)
+
+	if _, err := w.file.WriteAt(loc, int64(offset)); err != nil {
+		return err
+	}
+
+	_, err := w.file.WriteAt(ts, int64(timestampOffset+offset))
+	return err
+}
+
+// Sync commits the region file to stable storage.
+func (w *RegionWriter) Sync() error {
+	return w.file.Sync()
+}
+
+func writeFileSync(filename string, data []byte) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
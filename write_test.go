@@ -0,0 +1,200 @@
+package anvil
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegionWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "r.0.-1.mca")
+	w, err := OpenRegionWriter(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	small := []byte("small chunk")
+	large := bytes.Repeat([]byte{0xaa}, 3*sectorSize)
+	huge := bytes.Repeat([]byte{0xbb}, (maxChunkSectors+1)*sectorSize)
+	modified := time.Unix(1600000000, 0)
+
+	a, b, c := Chunk{X: 0, Z: -32}, Chunk{X: 1, Z: -32}, Chunk{X: 31, Z: -1}
+
+	assert.NoError(t, w.WriteChunk(a, small))
+	assert.NoError(t, w.WriteChunk(b, small))
+	assert.Equal(t, 2, w.sectorOffset(a.RegionChunkOffset()))
+	assert.Equal(t, 3, w.sectorOffset(b.RegionChunkOffset()))
+
+	// growing a chunk moves it to the end of the file, and the sectors it
+	// freed are reused by the next write
+	w.Compression = CompressionUncompressed
+	assert.NoError(t, w.WriteChunk(a, large))
+	assert.Equal(t, 4, w.sectorOffset(a.RegionChunkOffset()))
+	assert.Equal(t, 4, w.sectorCount(a.RegionChunkOffset()))
+	assert.NoError(t, w.WriteChunk(b, small))
+	assert.Equal(t, 2, w.sectorOffset(b.RegionChunkOffset()))
+
+	// rewriting a chunk doesn't write over the sectors it's still using
+	assert.NoError(t, w.WriteChunk(b, small))
+	assert.Equal(t, 3, w.sectorOffset(b.RegionChunkOffset()))
+
+	assert.NoError(t, w.WriteRawChunk(ChunkData{
+		Chunk:        c,
+		Compression:  CompressionUncompressed,
+		Data:         huge,
+		LastModified: modified,
+	}))
+	assert.Error(t, w.WriteChunk(Chunk{X: 0, Z: 0}, small))
+
+	assert.NoError(t, w.Close())
+
+	rd, err := OpenRegionFile(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for chunk, expected := range map[Chunk][]byte{a: large, b: small, c: huge} {
+		data, err := rd.ReadChunk(chunk)
+		assert.NoError(t, err)
+		decompressed, err := data.Decompress()
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(expected, decompressed), chunk)
+		assert.Equal(t, chunk == c, data.External)
+	}
+
+	assert.Equal(t, modified, rd.LastModified(c))
+	assert.NoError(t, rd.Close())
+
+	w, err = OpenRegionWriter(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Close()
+
+	assert.NoError(t, w.DeleteChunk(c))
+	assert.NoError(t, w.DeleteChunk(c))
+	_, err = os.Stat(filepath.Join(dir, ExternalChunkFilename(c)))
+	assert.True(t, os.IsNotExist(err))
+
+	data, err := w.ReadChunk(c)
+	assert.True(t, errors.Is(err, ErrChunkNotPresent))
+	assert.Nil(t, data.Data)
+	assert.Len(t, w.Timestamps(), 2)
+
+	// the free space map is rebuilt on open, so sector 2 is reused
+	assert.NoError(t, w.WriteChunk(c, small))
+	assert.Equal(t, 2, w.sectorOffset(c.RegionChunkOffset()))
+}
+
+func TestRegionWriterAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anvil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	regionDir := filepath.Join(dir, "region")
+	if err := os.Mkdir(regionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, SessionLockFilename), []byte("\xe2\x98\x83"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked, err := WorldLocked(dir)
+	assert.NoError(t, err)
+	assert.False(t, locked)
+
+	filename := filepath.Join(regionDir, "r.0.0.mca")
+	chunk := Chunk{X: 3, Z: 4}
+	huge := bytes.Repeat([]byte{0xcc}, (maxChunkSectors+1)*sectorSize)
+
+	readChunk := func() []byte {
+		rd, err := OpenRegionFile(filename)
+		if err != nil {
+			return nil
+		}
+		defer rd.Close()
+
+		c, err := rd.ReadChunk(chunk)
+		assert.NoError(t, err)
+		data, _ := c.Decompress()
+		return data
+	}
+
+	w, err := OpenRegionWriterAtomic(filename, AtomicOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.WriteChunk(chunk, []byte("discarded")))
+	assert.NoError(t, w.Close())
+	assert.Error(t, w.Commit())
+
+	_, err = os.Stat(filename)
+	assert.True(t, os.IsNotExist(err))
+
+	w, err = OpenRegionWriterAtomic(filename, AtomicOptions{Backup: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.WriteChunk(chunk, []byte("first")))
+	assert.NoError(t, w.Commit())
+	assert.Equal(t, []byte("first"), readChunk())
+
+	w, err = OpenRegionWriterAtomic(filename, AtomicOptions{Backup: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Compression = CompressionUncompressed
+	assert.NoError(t, w.WriteChunk(chunk, huge))
+
+	// the external chunk is readable from the writer, but not written yet
+	c, err := w.ReadChunk(chunk)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(huge, c.Data))
+	assert.Equal(t, []byte("first"), readChunk())
+	_, err = os.Stat(filepath.Join(regionDir, ExternalChunkFilename(chunk)))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, w.Commit())
+	assert.True(t, bytes.Equal(huge, readChunk()))
+
+	files, err := ioutil.ReadDir(regionDir)
+	assert.NoError(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"c.3.4.mcc", "r.0.0.mca", "r.0.0.mca.bak"}, names)
+
+	// new regions are 0644, and rewritten regions keep their permissions
+	info, err := os.Stat(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	assert.NoError(t, os.Chmod(filename, 0640))
+	w, err = OpenRegionWriterAtomic(filename, AtomicOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.WriteChunk(chunk, []byte("second")))
+	assert.NoError(t, w.Commit())
+
+	info, err = os.Stat(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}